@@ -0,0 +1,35 @@
+package main
+
+import (
+	"github.com/appleboy/deploy-k8s/report"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// applyAction returns what the apply did to the live object, nil if it did not exist.
+// The API server keeps the resourceVersion and the generation when nothing changed.
+func applyAction(live, applied *unstructured.Unstructured) report.Action {
+	if live == nil {
+		return report.ActionCreated
+	}
+	if live.GetResourceVersion() == applied.GetResourceVersion() &&
+		live.GetGeneration() == applied.GetGeneration() {
+		return report.ActionUnchanged
+	}
+	return report.ActionConfigured
+}
+
+// sameObject returns whether the dry-run result of the apply equals the live object.
+// A dry-run does not store the object, so the metadata changed by every write is ignored.
+func sameObject(live, dryRun *unstructured.Unstructured) bool {
+	return equality.Semantic.DeepEqual(withoutWriteMetadata(live), withoutWriteMetadata(dryRun))
+}
+
+func withoutWriteMetadata(obj *unstructured.Unstructured) map[string]interface{} {
+	c := obj.DeepCopy()
+	c.SetManagedFields(nil)
+	c.SetResourceVersion("")
+	c.SetGeneration(0)
+	return c.Object
+}
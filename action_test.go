@@ -0,0 +1,109 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/appleboy/deploy-k8s/config"
+	"github.com/appleboy/deploy-k8s/report"
+	"github.com/appleboy/deploy-k8s/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newConfigMap(resourceVersion string, data map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":            "config",
+			"namespace":       "default",
+			"resourceVersion": resourceVersion,
+			"managedFields": []interface{}{
+				map[string]interface{}{"manager": "deploy-k8s-plugin", "time": resourceVersion},
+			},
+		},
+		"data": data,
+	}}
+}
+
+func TestApplyAction(t *testing.T) {
+	live := newConfigMap("1", nil)
+	live.SetGeneration(2)
+	tests := []struct {
+		name    string
+		live    *unstructured.Unstructured
+		applied func(*unstructured.Unstructured)
+		want    report.Action
+	}{
+		{"created", nil, func(*unstructured.Unstructured) {}, report.ActionCreated},
+		{"unchanged", live, func(*unstructured.Unstructured) {}, report.ActionUnchanged},
+		{"configured", live, func(u *unstructured.Unstructured) { u.SetResourceVersion("2") }, report.ActionConfigured},
+		{"generation", live, func(u *unstructured.Unstructured) { u.SetGeneration(3) }, report.ActionConfigured},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := live.DeepCopy()
+			tt.applied(applied)
+			if got := applyAction(tt.live, applied); got != tt.want {
+				t.Errorf("applyAction() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameObject(t *testing.T) {
+	live := newConfigMap("1", map[string]interface{}{"key": "value"})
+	if !sameObject(live, newConfigMap("2", map[string]interface{}{"key": "value"})) {
+		t.Error("sameObject() = false, want true when only the write metadata differs")
+	}
+	if sameObject(live, newConfigMap("1", map[string]interface{}{"key": "changed"})) {
+		t.Error("sameObject() = true, want false when the data differs")
+	}
+}
+
+func TestApplyObject(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	want := newConfigMap("", map[string]interface{}{"key": "value"})
+
+	tests := []struct {
+		name          string
+		live          *unstructured.Unstructured
+		result        *unstructured.Unstructured
+		skipUnchanged bool
+		action        report.Action
+		applies       int
+	}{
+		{"created", nil, newConfigMap("1", map[string]interface{}{"key": "value"}), true, report.ActionCreated, 1},
+		{"unchanged", newConfigMap("1", map[string]interface{}{"key": "value"}), newConfigMap("1", map[string]interface{}{"key": "value"}), false, report.ActionUnchanged, 1},
+		{"skip unchanged", newConfigMap("1", map[string]interface{}{"key": "value"}), newConfigMap("1", map[string]interface{}{"key": "value"}), true, report.ActionUnchanged, 1},
+		{"configured", newConfigMap("1", map[string]interface{}{"key": "old"}), newConfigMap("2", map[string]interface{}{"key": "value"}), true, report.ActionConfigured, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objs []runtime.Object
+			if tt.live != nil {
+				objs = append(objs, tt.live)
+			}
+			client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{gvr: "ConfigMapList"}, objs...)
+			applies := 0
+			client.PrependReactor("patch", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+				applies++
+				return true, tt.result.DeepCopy(), nil
+			})
+
+			p := &Plugin{Config: &config.K8S{SkipUnchanged: tt.skipUnchanged}}
+			action, err := p.applyObject(client.Resource(gvr).Namespace("default"), &template.KubeObject{Obj: want.DeepCopy()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != tt.action || applies != tt.applies {
+				t.Errorf("applyObject() = %s with %d applies, want %s with %d", action, applies, tt.action, tt.applies)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/appleboy/deploy-k8s/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	annotationChangeCause = "kubernetes.io/change-cause"
+	annotationCommitSHA   = "deploy-k8s/commit-sha"
+	annotationBuildURL    = "deploy-k8s/build-url"
+	annotationActor       = "deploy-k8s/actor"
+)
+
+// the variables of the deploy metadata by CI provider, the first non-empty wins:
+// Drone, GitHub Actions, GitLab and Woodpecker (CI_), Jenkins and Buildkite.
+var (
+	commitSHAEnvs = []string{
+		"drone_commit_sha", "github_sha", "ci_commit_sha", "git_commit", "buildkite_commit",
+	}
+	actorEnvs = []string{
+		"drone_commit_author", "github_actor", "gitlab_user_login", "ci_commit_author",
+		"build_user_id", "change_author", "buildkite_build_creator",
+	}
+	buildURLEnvs = []string{
+		"drone_build_link", "ci_pipeline_url", "build_url", "buildkite_build_url",
+	}
+)
+
+// isWorkload reports whether the kind owns a pod template.
+func isWorkload(gvk schema.GroupVersionKind) bool {
+	if gvk.Group != "apps" {
+		return false
+	}
+	switch gvk.Kind {
+	case "Deployment", "StatefulSet", "DaemonSet":
+		return true
+	}
+	return false
+}
+
+// firstEnv returns the first non-empty value of the given keys.
+func firstEnv(envs map[string]any, keys ...string) string {
+	for _, key := range keys {
+		if v, ok := envs[key].(string); ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// buildURL returns the link of the current CI build, GitHub Actions has no variable of the run URL.
+func buildURL(envs map[string]any) string {
+	if v := firstEnv(envs, buildURLEnvs...); v != "" {
+		return v
+	}
+	server := firstEnv(envs, "github_server_url")
+	repo := firstEnv(envs, "github_repository")
+	runID := firstEnv(envs, "github_run_id")
+	if server == "" || repo == "" || runID == "" {
+		return ""
+	}
+	return server + "/" + repo + "/actions/runs/" + runID
+}
+
+// defaultChangeCause returns a change cause built from the CI metadata.
+func defaultChangeCause(sha, url, actor string) string {
+	parts := []string{"deploy-k8s"}
+	if sha != "" {
+		parts = append(parts, "commit "+sha)
+	}
+	if actor != "" {
+		parts = append(parts, "by "+actor)
+	}
+	if url != "" {
+		parts = append(parts, "("+url+")")
+	}
+	return strings.Join(parts, " ")
+}
+
+// deployAnnotations returns the annotations recorded on the updated workloads.
+func (p *Plugin) deployAnnotations(envs map[string]any) (map[string]string, error) {
+	annotations := map[string]string{}
+
+	if p.Config.Record {
+		sha := firstEnv(envs, commitSHAEnvs...)
+		url := buildURL(envs)
+		actor := firstEnv(envs, actorEnvs...)
+
+		if sha != "" {
+			annotations[annotationCommitSHA] = sha
+		}
+		if url != "" {
+			annotations[annotationBuildURL] = url
+		}
+		if actor != "" {
+			annotations[annotationActor] = actor
+		}
+
+		cause := defaultChangeCause(sha, url, actor)
+		if p.Config.ChangeCause != "" {
+			out, err := template.NewTemplate(p.Config.ChangeCause, envs)
+			if err != nil {
+				return nil, fmt.Errorf("parse change cause failed: %w", err)
+			}
+			cause = string(out)
+		}
+		annotations[annotationChangeCause] = cause
+	}
+
+	for _, v := range p.Config.Annotations {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid annotation %q, expected key=value", v)
+		}
+		out, err := template.NewTemplate(value, envs)
+		if err != nil {
+			return nil, fmt.Errorf("parse annotation %s failed: %w", key, err)
+		}
+		annotations[key] = string(out)
+	}
+
+	return annotations, nil
+}
+
+// setWorkloadAnnotations merges annotations into the workload and its pod template.
+func setWorkloadAnnotations(obj *unstructured.Unstructured, annotations map[string]string) error {
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	current := obj.GetAnnotations()
+	if current == nil {
+		current = map[string]string{}
+	}
+	for k, v := range annotations {
+		current[k] = v
+	}
+	obj.SetAnnotations(current)
+
+	tpl, _, err := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "annotations")
+	if err != nil {
+		return err
+	}
+	if tpl == nil {
+		tpl = map[string]string{}
+	}
+	for k, v := range annotations {
+		tpl[k] = v
+	}
+	return unstructured.SetNestedStringMap(obj.Object, tpl, "spec", "template", "metadata", "annotations")
+}
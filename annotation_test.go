@@ -0,0 +1,129 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/appleboy/deploy-k8s/config"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDeployAnnotations(t *testing.T) {
+	p := &Plugin{
+		Config: &config.K8S{
+			Record:      true,
+			Annotations: []string{"team={{ .envs.team }}"},
+		},
+	}
+
+	envs := map[string]any{
+		"github_sha":        "abc123",
+		"github_actor":      "appleboy",
+		"github_server_url": "https://github.com",
+		"github_repository": "appleboy/deploy-k8s",
+		"github_run_id":     "42",
+		"team":              "backend",
+	}
+
+	annotations, err := p.deployAnnotations(envs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		annotationCommitSHA:   "abc123",
+		annotationActor:       "appleboy",
+		annotationBuildURL:    "https://github.com/appleboy/deploy-k8s/actions/runs/42",
+		annotationChangeCause: "deploy-k8s commit abc123 by appleboy (https://github.com/appleboy/deploy-k8s/actions/runs/42)",
+		"team":                "backend",
+	}
+	for k, v := range expected {
+		if annotations[k] != v {
+			t.Errorf("Expected %s: %s, got: %s", k, v, annotations[k])
+		}
+	}
+
+	p.Config.ChangeCause = "release {{ .envs.github_sha }}"
+	annotations, err = p.deployAnnotations(envs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if annotations[annotationChangeCause] != "release abc123" {
+		t.Errorf("Expected change cause: release abc123, got: %s", annotations[annotationChangeCause])
+	}
+
+	// the variables of the other CI providers
+	tests := []struct {
+		name string
+		envs map[string]any
+		url  string
+	}{
+		{
+			"gitlab",
+			map[string]any{"ci_commit_sha": "abc123", "gitlab_user_login": "appleboy", "ci_pipeline_url": "https://gitlab.com/acme/app/-/pipelines/42"},
+			"https://gitlab.com/acme/app/-/pipelines/42",
+		},
+		{
+			"woodpecker",
+			map[string]any{"ci_commit_sha": "abc123", "ci_commit_author": "appleboy", "ci_pipeline_url": "https://ci.example.com/repos/1/pipeline/42"},
+			"https://ci.example.com/repos/1/pipeline/42",
+		},
+		{
+			"jenkins",
+			map[string]any{"git_commit": "abc123", "build_user_id": "appleboy", "build_url": "https://jenkins.example.com/job/app/42/"},
+			"https://jenkins.example.com/job/app/42/",
+		},
+		{
+			"buildkite",
+			map[string]any{"buildkite_commit": "abc123", "buildkite_build_creator": "appleboy", "buildkite_build_url": "https://buildkite.com/acme/app/builds/42"},
+			"https://buildkite.com/acme/app/builds/42",
+		},
+	}
+	for _, tt := range tests {
+		p := &Plugin{Config: &config.K8S{Record: true}}
+		annotations, err := p.deployAnnotations(tt.envs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		if annotations[annotationCommitSHA] != "abc123" || annotations[annotationActor] != "appleboy" || annotations[annotationBuildURL] != tt.url {
+			t.Errorf("Unexpected %s annotations: %v", tt.name, annotations)
+		}
+	}
+
+	p.Config.Annotations = []string{"invalid"}
+	if _, err := p.deployAnnotations(envs); err == nil {
+		t.Errorf("Expected error for invalid annotation")
+	}
+}
+
+func TestSetWorkloadAnnotations(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name": "nginx",
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						"foo": "bar",
+					},
+				},
+			},
+		},
+	}}
+
+	if err := setWorkloadAnnotations(obj, map[string]string{annotationCommitSHA: "abc123"}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if obj.GetAnnotations()[annotationCommitSHA] != "abc123" {
+		t.Errorf("Expected workload annotation, got: %v", obj.GetAnnotations())
+	}
+
+	tpl, _, _ := unstructured.NestedStringMap(obj.Object, "spec", "template", "metadata", "annotations")
+	if tpl[annotationCommitSHA] != "abc123" || tpl["foo"] != "bar" {
+		t.Errorf("Expected pod template annotations, got: %v", tpl)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/appleboy/deploy-k8s/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const annotationConfigChecksum = "deploy-k8s/config-checksum"
+
+// configRef is a reference to a ConfigMap or Secret.
+type configRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (r configRef) String() string {
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// objectNamespace returns the namespace of the object or the default namespace.
+func objectNamespace(obj *unstructured.Unstructured, namespace string) string {
+	if ns := obj.GetNamespace(); ns != "" {
+		return ns
+	}
+	return namespace
+}
+
+// configChecksums returns the hash of the data of every ConfigMap and Secret.
+func configChecksums(objs []*template.KubeObject, namespace string) (map[configRef]string, error) {
+	checksums := map[configRef]string{}
+	for _, v := range objs {
+		var fields []string
+		switch {
+		case v.GVK.Group == "" && v.GVK.Kind == "ConfigMap":
+			fields = []string{"data", "binaryData"}
+		case v.GVK.Group == "" && v.GVK.Kind == "Secret":
+			fields = []string{"data", "stringData"}
+		default:
+			continue
+		}
+
+		content := map[string]interface{}{}
+		for _, field := range fields {
+			if data, ok := v.Obj.Object[field]; ok {
+				content[field] = data
+			}
+		}
+		// json.Marshal sorts the map keys, so the hash is stable
+		data, err := json.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		checksums[configRef{
+			Kind:      v.GVK.Kind,
+			Namespace: objectNamespace(v.Obj, namespace),
+			Name:      v.Obj.GetName(),
+		}] = hex.EncodeToString(sum[:])
+	}
+	return checksums, nil
+}
+
+// configReferences returns the ConfigMaps and Secrets referenced by the pod template
+// through volumes, envFrom or valueFrom.
+func configReferences(obj *unstructured.Unstructured, namespace string) []configRef {
+	ns := objectNamespace(obj, namespace)
+	refs := []configRef{}
+	add := func(kind string, item map[string]interface{}, fields ...string) {
+		name, found, _ := unstructured.NestedString(item, fields...)
+		if found && name != "" {
+			refs = append(refs, configRef{Kind: kind, Namespace: ns, Name: name})
+		}
+	}
+
+	volumes, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "volumes")
+	for _, v := range volumes {
+		volume, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		add("ConfigMap", volume, "configMap", "name")
+		add("Secret", volume, "secret", "secretName")
+
+		sources, _, _ := unstructured.NestedSlice(volume, "projected", "sources")
+		for _, s := range sources {
+			if source, ok := s.(map[string]interface{}); ok {
+				add("ConfigMap", source, "configMap", "name")
+				add("Secret", source, "secret", "name")
+			}
+		}
+	}
+
+	for _, field := range []string{"initContainers", "containers"} {
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", field)
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			envFrom, _, _ := unstructured.NestedSlice(container, "envFrom")
+			for _, e := range envFrom {
+				if item, ok := e.(map[string]interface{}); ok {
+					add("ConfigMap", item, "configMapRef", "name")
+					add("Secret", item, "secretRef", "name")
+				}
+			}
+
+			envs, _, _ := unstructured.NestedSlice(container, "env")
+			for _, e := range envs {
+				if item, ok := e.(map[string]interface{}); ok {
+					add("ConfigMap", item, "valueFrom", "configMapKeyRef", "name")
+					add("Secret", item, "valueFrom", "secretKeyRef", "name")
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+// setConfigChecksum stamps the checksum of the referenced ConfigMaps and Secrets
+// on the pod template of every workload, so pods roll when the config changes.
+func setConfigChecksum(objs []*template.KubeObject, namespace string) error {
+	checksums, err := configChecksums(objs, namespace)
+	if err != nil {
+		return err
+	}
+	if len(checksums) == 0 {
+		return nil
+	}
+
+	for _, v := range objs {
+		if !isWorkload(v.GVK) {
+			continue
+		}
+
+		items := []string{}
+		seen := map[configRef]bool{}
+		for _, ref := range configReferences(v.Obj, namespace) {
+			sum, ok := checksums[ref]
+			if !ok || seen[ref] {
+				continue
+			}
+			seen[ref] = true
+			items = append(items, ref.String()+"="+sum)
+		}
+		if len(items) == 0 {
+			continue
+		}
+		sort.Strings(items)
+
+		sum := sha256.Sum256([]byte(strings.Join(items, "\n")))
+		tpl, _, err := unstructured.NestedStringMap(v.Obj.Object, "spec", "template", "metadata", "annotations")
+		if err != nil {
+			return err
+		}
+		if tpl == nil {
+			tpl = map[string]string{}
+		}
+		tpl[annotationConfigChecksum] = hex.EncodeToString(sum[:])
+		if err := unstructured.SetNestedStringMap(
+			v.Obj.Object, tpl,
+			"spec", "template", "metadata", "annotations",
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/appleboy/deploy-k8s/template"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func parseKubeObjects(t *testing.T, data string) []*template.KubeObject {
+	t.Helper()
+	objs, err := template.ParseObject([]byte(data))
+	if err != nil {
+		t.Fatalf("Error parsing objects: %v", err)
+	}
+	result := make([]*template.KubeObject, 0, len(objs))
+	for i := range objs {
+		result = append(result, &template.KubeObject{
+			GVK: objs[i].GroupVersionKind(),
+			Obj: &objs[i],
+		})
+	}
+	return result
+}
+
+func TestSetConfigChecksum(t *testing.T) {
+	manifests := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: %s
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+stringData:
+  password: secret
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      volumes:
+      - name: config
+        configMap:
+          name: app-config
+      containers:
+      - name: app
+        image: app
+        env:
+        - name: PASSWORD
+          valueFrom:
+            secretKeyRef:
+              name: app-secret
+              key: password
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other
+spec:
+  template:
+    spec:
+      containers:
+      - name: other
+        image: other
+        envFrom:
+        - configMapRef:
+            name: unknown
+`
+
+	checksum := func(value string) (string, string) {
+		objs := parseKubeObjects(t, fmt.Sprintf(manifests, value))
+		if err := setConfigChecksum(objs, "default"); err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		app, _, _ := unstructured.NestedString(objs[2].Obj.Object, "spec", "template", "metadata", "annotations", annotationConfigChecksum)
+		other, _, _ := unstructured.NestedString(objs[3].Obj.Object, "spec", "template", "metadata", "annotations", annotationConfigChecksum)
+		return app, other
+	}
+
+	first, other := checksum("value1")
+	if first == "" {
+		t.Errorf("Expected config checksum annotation on app deployment")
+	}
+	if other != "" {
+		t.Errorf("Expected no config checksum annotation on other deployment, got: %s", other)
+	}
+
+	second, _ := checksum("value1")
+	if first != second {
+		t.Errorf("Expected stable checksum, got: %s and %s", first, second)
+	}
+
+	third, _ := checksum("value2")
+	if first == third {
+		t.Errorf("Expected checksum changed when config data changed")
+	}
+}
+
+func TestConfigReferences(t *testing.T) {
+	objs := parseKubeObjects(t, `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: app
+  namespace: testing
+spec:
+  template:
+    spec:
+      volumes:
+      - name: projected
+        projected:
+          sources:
+          - configMap:
+              name: projected-config
+          - secret:
+              name: projected-secret
+      - name: secret
+        secret:
+          secretName: volume-secret
+      initContainers:
+      - name: init
+        envFrom:
+        - secretRef:
+            name: init-secret
+      containers:
+      - name: app
+        env:
+        - name: KEY
+          valueFrom:
+            configMapKeyRef:
+              name: env-config
+              key: key
+`)
+
+	refs := configReferences(objs[0].Obj, "default")
+	expected := []string{
+		"ConfigMap/testing/projected-config",
+		"Secret/testing/projected-secret",
+		"Secret/testing/volume-secret",
+		"Secret/testing/init-secret",
+		"ConfigMap/testing/env-config",
+	}
+	if len(refs) != len(expected) {
+		t.Fatalf("Expected %d references, got: %v", len(expected), refs)
+	}
+	for i, ref := range refs {
+		if ref.String() != expected[i] {
+			t.Errorf("Expected reference: %s, got: %s", expected[i], ref)
+		}
+	}
+}
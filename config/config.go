@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 type (
 	// Config for the kube server.
 	K8S struct {
@@ -11,10 +13,80 @@ type (
 		Templates []string
 		Output    string
 		Debug     bool
+		LogFormat string
+
+		// template rendering
+		TemplateHelpers []string
+		Values          []string
+		Set             []string
+		Strict          bool
+		Kustomize       []string
+		// skip template patterns matching no files
+		AllowMissingTemplates bool
+		// files loaded from template directories
+		TemplateInclude []string
+		TemplateExclude []string
+
+		// environment variables exposed as .envs
+		EnvProfile []string
+		EnvPrefix  []string
+		EnvAllow   []string
+		EnvTyped   bool
+
+		// local helm chart
+		Chart              string
+		ChartRelease       string
+		ChartRecordRelease bool
+
+		// deploy (default), restart, render or validate
+		Action      string
+		Wait        bool
+		WaitTimeout time.Duration
+
+		// skip the objects a dry-run apply would not change
+		SkipUnchanged bool
+
+		// container log lines printed in the diagnosis of a failed deploy
+		DiagnoseLogLines int64
+
+		// render output directory and format (yaml or json)
+		RenderOutput string
+		RenderFormat string
+
+		// report files (.json, .xml or .md) and the GitHub Actions job summary
+		Report        []string
+		ReportSummary bool
+
+		// validate the objects against the OpenAPI schemas before apply
+		Validate       bool
+		KubeVersion    string
+		SchemaLocation string
+		SchemaCache    string
+		CRDSchemas     []string
+
+		// policy rules checked before apply
+		Policy            []string
+		AllowedRegistries []string
+		PolicyFiles       []string
 
 		Deployment []string
+		Selector   string
 		Container  []string
 		Image      string
+		Replicas   string
+
+		// container spec changes, container:KEY=VALUE format
+		SetEnv       []string
+		UnsetEnv     []string
+		SetResources []string
+
+		// deploy metadata annotations
+		Record      bool
+		ChangeCause string
+		Annotations []string
+
+		// restart workloads when the referenced ConfigMaps or Secrets change
+		ConfigChecksum bool
 
 		// kube config file
 		ClusterName  string
@@ -24,5 +96,7 @@ type (
 
 	AuthInfo struct {
 		Token string
+		// age identities to decrypt sops files
+		AgeKey string
 	}
 )
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// containerSetting is a single change for a named container,
+// parsed from the container:KEY=VALUE format.
+type containerSetting struct {
+	Container string
+	Key       string
+	Value     string
+}
+
+// parseContainerSettings parses a list of container:KEY=VALUE settings.
+// The =VALUE part is required only if withValue is true.
+func parseContainerSettings(values []string, withValue bool) ([]containerSetting, error) {
+	settings := make([]containerSetting, 0, len(values))
+	for _, v := range values {
+		container, kv, ok := strings.Cut(v, ":")
+		if !ok || container == "" || kv == "" {
+			return nil, fmt.Errorf("invalid setting %q, expected container:KEY=VALUE", v)
+		}
+
+		setting := containerSetting{
+			Container: container,
+			Key:       kv,
+		}
+		if withValue {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok || key == "" {
+				return nil, fmt.Errorf("invalid setting %q, expected container:KEY=VALUE", v)
+			}
+			setting.Key = key
+			setting.Value = value
+		}
+		settings = append(settings, setting)
+	}
+	return settings, nil
+}
+
+// setContainerEnv sets the env variable in the container spec,
+// replacing any existing value or valueFrom.
+func setContainerEnv(container map[string]interface{}, name, value string) error {
+	envs, _, err := unstructured.NestedSlice(container, "env")
+	if err != nil {
+		return err
+	}
+
+	for i, env := range envs {
+		item, ok := env.(map[string]interface{})
+		if !ok || item["name"] != name {
+			continue
+		}
+		delete(item, "valueFrom")
+		item["value"] = value
+		envs[i] = item
+		return unstructured.SetNestedSlice(container, envs, "env")
+	}
+
+	envs = append(envs, map[string]interface{}{
+		"name":  name,
+		"value": value,
+	})
+	return unstructured.SetNestedSlice(container, envs, "env")
+}
+
+// unsetContainerEnv removes the env variable from the container spec.
+func unsetContainerEnv(container map[string]interface{}, name string) error {
+	envs, found, err := unstructured.NestedSlice(container, "env")
+	if err != nil || !found {
+		return err
+	}
+
+	result := make([]interface{}, 0, len(envs))
+	for _, env := range envs {
+		if item, ok := env.(map[string]interface{}); ok && item["name"] == name {
+			continue
+		}
+		result = append(result, env)
+	}
+	return unstructured.SetNestedSlice(container, result, "env")
+}
+
+// parseResourceSettings parses a list of container:limits.NAME=QUANTITY settings
+// and validates the resource names and quantities.
+func parseResourceSettings(values []string) ([]containerSetting, error) {
+	settings, err := parseContainerSettings(values, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		if _, _, err := parseResource(s.Key, s.Value); err != nil {
+			return nil, err
+		}
+	}
+	return settings, nil
+}
+
+// parseResource splits the resource key like limits.cpu and validates the quantity.
+func parseResource(key, value string) (string, string, error) {
+	kind, name, ok := strings.Cut(key, ".")
+	if !ok || (kind != "limits" && kind != "requests") || name == "" {
+		return "", "", fmt.Errorf("invalid resource %q, expected limits.NAME or requests.NAME", key)
+	}
+	if _, err := resource.ParseQuantity(value); err != nil {
+		return "", "", fmt.Errorf("invalid resource quantity %q for %s: %w", value, key, err)
+	}
+	return kind, name, nil
+}
+
+// setContainerResource sets a resource quantity like limits.cpu in the container spec.
+func setContainerResource(container map[string]interface{}, key, value string) error {
+	kind, name, err := parseResource(key, value)
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedField(container, value, "resources", kind, name)
+}
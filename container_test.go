@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appleboy/deploy-k8s/config"
+	"github.com/appleboy/deploy-k8s/report"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestParseContainerSettings(t *testing.T) {
+	settings, err := parseContainerSettings([]string{"nginx:RELEASE_VERSION=v1.2.3", "app:EMPTY="}, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(settings) != 2 {
+		t.Fatalf("Expected 2 settings, got: %d", len(settings))
+	}
+	if settings[0].Container != "nginx" || settings[0].Key != "RELEASE_VERSION" || settings[0].Value != "v1.2.3" {
+		t.Errorf("Unexpected setting: %+v", settings[0])
+	}
+	if settings[1].Container != "app" || settings[1].Key != "EMPTY" || settings[1].Value != "" {
+		t.Errorf("Unexpected setting: %+v", settings[1])
+	}
+
+	settings, err = parseContainerSettings([]string{"nginx:DEBUG"}, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if settings[0].Container != "nginx" || settings[0].Key != "DEBUG" {
+		t.Errorf("Unexpected setting: %+v", settings[0])
+	}
+
+	for _, v := range []string{"nginx", ":KEY=VALUE", "nginx:KEY", "nginx:=VALUE"} {
+		if _, err := parseContainerSettings([]string{v}, true); err == nil {
+			t.Errorf("Expected error for %q", v)
+		}
+	}
+}
+
+func TestContainerEnv(t *testing.T) {
+	container := map[string]interface{}{
+		"name":  "nginx",
+		"image": "nginx:1.25.0",
+		"env": []interface{}{
+			map[string]interface{}{"name": "FOO", "value": "bar"},
+			map[string]interface{}{
+				"name": "SECRET",
+				"valueFrom": map[string]interface{}{
+					"secretKeyRef": map[string]interface{}{"name": "app", "key": "secret"},
+				},
+			},
+		},
+	}
+
+	if err := setContainerEnv(container, "SECRET", "plain"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := setContainerEnv(container, "RELEASE_VERSION", "v1.2.3"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if err := unsetContainerEnv(container, "FOO"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	envs, _, _ := unstructured.NestedSlice(container, "env")
+	if len(envs) != 2 {
+		t.Fatalf("Expected 2 env variables, got: %v", envs)
+	}
+	secret := envs[0].(map[string]interface{})
+	if secret["name"] != "SECRET" || secret["value"] != "plain" || secret["valueFrom"] != nil {
+		t.Errorf("Unexpected env: %v", secret)
+	}
+	release := envs[1].(map[string]interface{})
+	if release["name"] != "RELEASE_VERSION" || release["value"] != "v1.2.3" {
+		t.Errorf("Unexpected env: %v", release)
+	}
+	if container["image"] != "nginx:1.25.0" {
+		t.Errorf("Expected image untouched, got: %v", container["image"])
+	}
+}
+
+func TestSetContainerResource(t *testing.T) {
+	container := map[string]interface{}{
+		"name": "nginx",
+		"resources": map[string]interface{}{
+			"limits": map[string]interface{}{"cpu": "500m"},
+		},
+	}
+
+	if err := setContainerResource(container, "limits.memory", "256Mi"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	limits, _, _ := unstructured.NestedStringMap(container, "resources", "limits")
+	if limits["cpu"] != "500m" || limits["memory"] != "256Mi" {
+		t.Errorf("Unexpected limits: %v", limits)
+	}
+
+	if err := setContainerResource(container, "limit.cpu", "1"); err == nil {
+		t.Errorf("Expected error for invalid resource key")
+	}
+	if err := setContainerResource(container, "requests.cpu", "abc"); err == nil {
+		t.Errorf("Expected error for invalid quantity")
+	}
+}
+
+func TestParseResourceSettings(t *testing.T) {
+	settings, err := parseResourceSettings([]string{"nginx:limits.cpu=500m", "nginx:requests.memory=128Mi"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(settings) != 2 || settings[1].Key != "requests.memory" || settings[1].Value != "128Mi" {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+
+	for _, v := range []string{"typo:limit.cpu=1", "nginx:requests.cpu=abc", "nginx:limits.=1", "nginx"} {
+		if _, err := parseResourceSettings([]string{v}); err == nil {
+			t.Errorf("Expected error for %q", v)
+		}
+	}
+}
+
+func TestUpdateContainers(t *testing.T) {
+	newObj := func(kind, name string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "app", "image": "app:v1"},
+						},
+					},
+				},
+			},
+		}}
+	}
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newObj("Deployment", "web"), newObj("StatefulSet", "db"))
+
+	image := func(w workload) interface{} {
+		obj, err := dyn.Resource(w.Resource).Namespace("default").Get(context.Background(), w.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+		containers, _, _ := unstructured.NestedSlice(obj.Object, "spec", "template", "spec", "containers")
+		return containers[0].(map[string]interface{})["image"]
+	}
+
+	p := &Plugin{
+		Config: &config.K8S{
+			Namespace:  "default",
+			Deployment: []string{"web", "sts/db"},
+			Container:  []string{"app"},
+			Image:      "app:v2",
+		},
+	}
+	if err := p.updateContainers(context.Background(), dyn); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	for _, w := range []workload{newWorkload("Deployment", "web"), newWorkload("StatefulSet", "db")} {
+		if image := image(w); image != "app:v2" {
+			t.Errorf("Expected image app:v2 in %s, got: %v", w, image)
+		}
+	}
+
+	// a setting of a container missing in all the workloads fails before any update
+	p.Config.SetEnv = []string{"typo:KEY=value"}
+	p.Config.Image = "app:v3"
+	if err := p.updateContainers(context.Background(), dyn); err == nil {
+		t.Errorf("Expected error for unmatched container")
+	}
+	if image := image(newWorkload("Deployment", "web")); image != "app:v2" {
+		t.Errorf("Expected unchanged image app:v2 after unmatched container, got: %v", image)
+	}
+
+	p.Config.Deployment = []string{"cronjob/backup"}
+	if err := p.updateContainers(context.Background(), dyn); err == nil {
+		t.Errorf("Expected error for unsupported workload kind")
+	}
+
+	// the updated workload is checked with the policies before the update
+	p.Config.SetEnv = nil
+	p.Config.Deployment = []string{"web"}
+	p.Config.Policy = []string{"latest-tag"}
+	p.Config.Image = "app:latest"
+	if err := p.updateContainers(context.Background(), dyn); err == nil || !strings.Contains(err.Error(), "policy check failed") {
+		t.Errorf("Expected policy error, got: %v", err)
+	}
+	if image := image(newWorkload("Deployment", "web")); image != "app:v2" {
+		t.Errorf("Expected unchanged image app:v2, got: %v", image)
+	}
+
+	// no workload is updated if any of them violates the policies
+	priv := newObj("Deployment", "priv")
+	_ = unstructured.SetNestedSlice(priv.Object, []interface{}{
+		map[string]interface{}{"name": "app", "image": "app:v1", "securityContext": map[string]interface{}{"privileged": true}},
+	}, "spec", "template", "spec", "containers")
+	if _, err := dyn.Resource(priv.GroupVersionKind().GroupVersion().WithResource("deployments")).Namespace("default").Create(context.Background(), priv, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	p.Config.Deployment = []string{"web", "priv"}
+	p.Config.Policy = []string{"privileged"}
+	p.Config.Image = "app:v4"
+	if err := p.updateContainers(context.Background(), dyn); err == nil || !strings.Contains(err.Error(), "policy check failed") {
+		t.Errorf("Expected policy error, got: %v", err)
+	}
+	if image := image(newWorkload("Deployment", "web")); image != "app:v2" {
+		t.Errorf("Expected unchanged image app:v2 of web, got: %v", image)
+	}
+	p.Config.Policy = nil
+	p.Config.Image = "app:v2"
+
+	// the failed workload is diagnosed
+	p.Config.Deployment = []string{"sts/missing"}
+	var objErr *objectError
+	if err := p.updateContainers(context.Background(), dyn); !errors.As(err, &objErr) || objErr.Kind != "StatefulSet" || objErr.Name != "missing" {
+		t.Errorf("Expected object error of statefulset/missing, got: %v", err)
+	}
+}
+
+func TestUpdateContainersWait(t *testing.T) {
+	newDeployment := func(name string, updated int64) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"spec": map[string]interface{}{
+				"replicas": int64(1),
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "app", "image": "app:v1"},
+						},
+					},
+				},
+			},
+			"status": map[string]interface{}{
+				"replicas":          updated,
+				"updatedReplicas":   updated,
+				"availableReplicas": updated,
+			},
+		}}
+	}
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), newDeployment("web", 1), newDeployment("api", 0))
+
+	p := &Plugin{
+		Config: &config.K8S{
+			Namespace:   "default",
+			Deployment:  []string{"web"},
+			Container:   []string{"app"},
+			Image:       "app:v2",
+			Wait:        true,
+			WaitTimeout: 10 * time.Millisecond,
+		},
+		report: report.New("deploy", "default"),
+	}
+	if err := p.updateContainers(context.Background(), dyn); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if len(p.report.Rollouts) != 1 || p.report.Rollouts[0].Status != report.StatusSuccess {
+		t.Errorf("Expected successful rollout of web, got: %+v", p.report.Rollouts)
+	}
+
+	// the rollout of api never finishes
+	p.Config.Deployment = []string{"api"}
+	var objErr *objectError
+	if err := p.updateContainers(context.Background(), dyn); !errors.As(err, &objErr) || objErr.Name != "api" {
+		t.Errorf("Expected object error of deployment/api, got: %v", err)
+	}
+	if len(p.report.Rollouts) != 2 || p.report.Rollouts[1].Status != report.StatusFailed {
+		t.Errorf("Expected failed rollout of api, got: %+v", p.report.Rollouts)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+// the time a CRD takes to be served, before its custom resources can be applied
+const crdEstablishTimeout = 30 * time.Second
+
+// isCRD reports whether the kind is a CustomResourceDefinition.
+func isCRD(gvk schema.GroupVersionKind) bool {
+	return gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition"
+}
+
+// waitForCRD waits until the CRD is established, like helm install does for the CRDs of a chart.
+func waitForCRD(ctx context.Context, dr dynamic.ResourceInterface, name string) error {
+	err := wait.PollUntilContextTimeout(ctx, time.Second, crdEstablishTimeout, true, func(ctx context.Context) (bool, error) {
+		obj, err := dr.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return crdEstablished(obj), nil
+	})
+	if err != nil {
+		return fmt.Errorf("wait for crd %s established failed: %w", name, err)
+	}
+	return nil
+}
+
+// crdEstablished reports whether the Established condition of the CRD is true.
+func crdEstablished(obj *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if ok && cond["type"] == "Established" && cond["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
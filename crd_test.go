@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestWaitForCRD(t *testing.T) {
+	newCRD := func(name, established string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apiextensions.k8s.io/v1",
+			"kind":       "CustomResourceDefinition",
+			"metadata":   map[string]interface{}{"name": name},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "NamesAccepted", "status": "True"},
+					map[string]interface{}{"type": "Established", "status": established},
+				},
+			},
+		}}
+	}
+	established := newCRD("crontabs.stable.example.com", "True")
+	if !isCRD(established.GroupVersionKind()) {
+		t.Errorf("Expected CRD kind")
+	}
+	if !crdEstablished(established) || crdEstablished(newCRD("pending", "False")) {
+		t.Errorf("Unexpected established conditions")
+	}
+
+	dyn := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), established)
+	dr := dyn.Resource(schema.GroupVersionResource{
+		Group:    "apiextensions.k8s.io",
+		Version:  "v1",
+		Resource: "customresourcedefinitions",
+	})
+	if err := waitForCRD(context.Background(), dr, "crontabs.stable.example.com"); err != nil {
+		t.Errorf("Unexpected error: %s", err)
+	}
+	if err := waitForCRD(context.Background(), dr, "missing.stable.example.com"); err == nil {
+		t.Errorf("Expected error for missing CRD")
+	}
+}
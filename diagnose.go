@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// the most recent warning events printed
+	diagnoseMaxEvents = 20
+	// the failing pods whose containers and logs are printed
+	diagnoseMaxPods = 3
+	diagnoseTimeout = 30 * time.Second
+)
+
+// diagnoseOutput is where the diagnosis is printed, next to the logs.
+var diagnoseOutput io.Writer = os.Stderr
+
+// objectError is the error of the apply, the update or the rollout of an object,
+// the failure is diagnosed with the events, the pods and the logs of the object.
+type objectError struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Err       error
+}
+
+func (e *objectError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *objectError) Unwrap() error {
+	return e.Err
+}
+
+// diagnose prints the diagnosis of the object which failed the deploy, if any.
+func (p *Plugin) diagnose(cfg *rest.Config, err error) {
+	var objErr *objectError
+	if !errors.As(err, &objErr) {
+		return
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		log.Warn().Err(err).Msg("create diagnosis client failed")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), diagnoseTimeout)
+	defer cancel()
+
+	diagnosis := collectDiagnosis(ctx, clientset, objErr, p.Config.DiagnoseLogLines)
+	title := fmt.Sprintf("diagnose %s/%s", strings.ToLower(objErr.Kind), objErr.Name)
+	switch p.Config.LogFormat {
+	case logFormatGitHub:
+		fmt.Fprintf(diagnoseOutput, "::group::%s\n%s::endgroup::\n", title, diagnosis)
+	case logFormatJSON:
+		log.Error().
+			Str("event", eventDiagnose).
+			Str("kind", objErr.Kind).
+			Str("namespace", objErr.Namespace).
+			Str("name", objErr.Name).
+			Str("diagnosis", diagnosis).
+			Msg(title)
+	default:
+		fmt.Fprintf(diagnoseOutput, "----- %s -----\n%s----- end of diagnosis -----\n", title, diagnosis)
+	}
+}
+
+// involvedObject is an object whose events are collected.
+type involvedObject struct {
+	Kind string
+	Name string
+}
+
+// collectDiagnosis returns the recent warning events of the object, its replica sets and pods,
+// the status of the failing containers and the last lines of their logs. The errors of the
+// requests are written in the diagnosis, so the other parts are still printed.
+func collectDiagnosis(ctx context.Context, clientset kubernetes.Interface, obj *objectError, logLines int64) string {
+	var b strings.Builder
+
+	involved, pods, err := relatedObjects(ctx, clientset, obj)
+	if err != nil {
+		fmt.Fprintf(&b, "get %s/%s failed: %v\n", obj.Kind, obj.Name, err)
+	}
+
+	events, err := warningEvents(ctx, clientset, obj.Namespace, involved)
+	switch {
+	case err != nil:
+		fmt.Fprintf(&b, "list events failed: %v\n", err)
+	case len(events) == 0:
+		b.WriteString("No warning events.\n")
+	default:
+		b.WriteString("Warning events:\n")
+		for _, e := range events {
+			fmt.Fprintf(&b, "  %s %s/%s %s: %s",
+				eventTime(e).UTC().Format(time.RFC3339), e.InvolvedObject.Kind, e.InvolvedObject.Name,
+				e.Reason, strings.TrimSpace(e.Message))
+			if e.Count > 1 {
+				fmt.Fprintf(&b, " (x%d)", e.Count)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	failing := 0
+	for i := range pods {
+		pod := &pods[i]
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		var problems []string
+		var logContainers []corev1.ContainerStatus
+		for _, s := range statuses {
+			problem := containerProblem(s)
+			if problem == "" {
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("  %s: %s\n", s.Name, problem))
+			// the container never started, like ImagePullBackOff
+			if s.State.Waiting != nil && s.LastTerminationState.Terminated == nil {
+				continue
+			}
+			logContainers = append(logContainers, s)
+		}
+		if len(problems) == 0 && pod.Status.Phase != corev1.PodPending && pod.Status.Phase != corev1.PodFailed {
+			continue
+		}
+
+		failing++
+		if failing > diagnoseMaxPods {
+			continue
+		}
+		fmt.Fprintf(&b, "Pod %s: %s\n", pod.Name, pod.Status.Phase)
+		for _, problem := range problems {
+			b.WriteString(problem)
+		}
+		if logLines <= 0 {
+			continue
+		}
+		for _, s := range logContainers {
+			previous := s.State.Running == nil && s.LastTerminationState.Terminated != nil
+			which := "last"
+			if previous {
+				which = "previous container, last"
+			}
+			fmt.Fprintf(&b, "  Logs of %s (%s %d lines):\n", s.Name, which, logLines)
+			logs, err := clientset.CoreV1().Pods(obj.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				Container: s.Name,
+				TailLines: &logLines,
+				Previous:  previous,
+			}).DoRaw(ctx)
+			if err != nil {
+				fmt.Fprintf(&b, "    get logs failed: %v\n", err)
+				continue
+			}
+			for _, line := range strings.Split(strings.TrimRight(string(logs), "\n"), "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+	}
+	if failing > diagnoseMaxPods {
+		fmt.Fprintf(&b, "%d more failing pods\n", failing-diagnoseMaxPods)
+	}
+	return b.String()
+}
+
+// relatedObjects returns the object, its replica sets and pods, and the pods of the workload.
+func relatedObjects(ctx context.Context, clientset kubernetes.Interface, obj *objectError) (map[involvedObject]bool, []corev1.Pod, error) {
+	involved := map[involvedObject]bool{{obj.Kind, obj.Name}: true}
+	apps := clientset.AppsV1()
+
+	var selector *metav1.LabelSelector
+	switch obj.Kind {
+	case "Deployment":
+		d, err := apps.Deployments(obj.Namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+		if err != nil {
+			return involved, nil, err
+		}
+		selector = d.Spec.Selector
+		sel, err := metav1.LabelSelectorAsSelector(selector)
+		if err != nil {
+			return involved, nil, err
+		}
+		replicaSets, err := apps.ReplicaSets(obj.Namespace).List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
+		if err != nil {
+			return involved, nil, err
+		}
+		for i := range replicaSets.Items {
+			if metav1.IsControlledBy(&replicaSets.Items[i], d) {
+				involved[involvedObject{"ReplicaSet", replicaSets.Items[i].Name}] = true
+			}
+		}
+	case "StatefulSet":
+		s, err := apps.StatefulSets(obj.Namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+		if err != nil {
+			return involved, nil, err
+		}
+		selector = s.Spec.Selector
+	case "DaemonSet":
+		d, err := apps.DaemonSets(obj.Namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+		if err != nil {
+			return involved, nil, err
+		}
+		selector = d.Spec.Selector
+	case "Pod":
+		pod, err := clientset.CoreV1().Pods(obj.Namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+		if err != nil {
+			return involved, nil, err
+		}
+		return involved, []corev1.Pod{*pod}, nil
+	default:
+		return involved, nil, nil
+	}
+
+	sel, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return involved, nil, err
+	}
+	list, err := clientset.CoreV1().Pods(obj.Namespace).List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
+	if err != nil {
+		return involved, nil, err
+	}
+	var pods []corev1.Pod
+	for _, pod := range list.Items {
+		// the pods of the workload, or of the replica sets of the deployment
+		owner := metav1.GetControllerOf(&pod)
+		if owner == nil || !involved[involvedObject{owner.Kind, owner.Name}] {
+			continue
+		}
+		involved[involvedObject{"Pod", pod.Name}] = true
+		pods = append(pods, pod)
+	}
+	return involved, pods, nil
+}
+
+// warningEvents returns the most recent warning events of the involved objects, oldest first.
+func warningEvents(ctx context.Context, clientset kubernetes.Interface, namespace string, involved map[involvedObject]bool) ([]corev1.Event, error) {
+	list, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "type=" + corev1.EventTypeWarning,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var events []corev1.Event
+	for _, e := range list.Items {
+		if e.Type == corev1.EventTypeWarning && involved[involvedObject{e.InvolvedObject.Kind, e.InvolvedObject.Name}] {
+			events = append(events, e)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return eventTime(events[i]).Before(eventTime(events[j]))
+	})
+	if len(events) > diagnoseMaxEvents {
+		events = events[len(events)-diagnoseMaxEvents:]
+	}
+	return events, nil
+}
+
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// containerProblem describes why the container is not ready, like ImagePullBackOff,
+// CrashLoopBackOff or OOMKilled, empty if the container is ready.
+func containerProblem(s corev1.ContainerStatus) string {
+	if s.Ready {
+		return ""
+	}
+	var parts []string
+	switch {
+	case s.State.Waiting != nil:
+		parts = append(parts, withMessage("waiting "+s.State.Waiting.Reason, s.State.Waiting.Message))
+	case s.State.Terminated != nil:
+		// completed init containers are not ready
+		if s.State.Terminated.ExitCode == 0 {
+			return ""
+		}
+		parts = append(parts, fmt.Sprintf("terminated %s (exit code %d)", s.State.Terminated.Reason, s.State.Terminated.ExitCode))
+	case s.State.Running != nil:
+		parts = append(parts, "running, not ready")
+	}
+	if s.RestartCount > 0 {
+		parts = append(parts, fmt.Sprintf("%d restarts", s.RestartCount))
+	}
+	if t := s.LastTerminationState.Terminated; t != nil {
+		parts = append(parts, fmt.Sprintf("last terminated %s (exit code %d)", t.Reason, t.ExitCode))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func withMessage(s, msg string) string {
+	if msg = strings.TrimSpace(msg); msg != "" {
+		return s + ": " + msg
+	}
+	return s
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestContainerProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		status corev1.ContainerStatus
+		want   string
+	}{
+		{"ready", corev1.ContainerStatus{Ready: true}, ""},
+		{
+			"image pull",
+			corev1.ContainerStatus{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+				Reason: "ImagePullBackOff", Message: `Back-off pulling image "web:v2"`,
+			}}},
+			`waiting ImagePullBackOff: Back-off pulling image "web:v2"`,
+		},
+		{
+			"crash loop",
+			corev1.ContainerStatus{
+				RestartCount:         4,
+				State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+			},
+			"waiting CrashLoopBackOff, 4 restarts, last terminated OOMKilled (exit code 137)",
+		},
+		{
+			"completed init container",
+			corev1.ContainerStatus{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Completed"}}},
+			"",
+		},
+		{
+			"not ready",
+			corev1.ContainerStatus{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}},
+			"running, not ready",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containerProblem(tt.status); got != tt.want {
+				t.Errorf("containerProblem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectDiagnosis(t *testing.T) {
+	labels := map[string]string{"app": "web"}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "deploy-uid"},
+		Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+	}
+	controller := func(kind, name, uid string) []metav1.OwnerReference {
+		isController := true
+		return []metav1.OwnerReference{{Kind: kind, Name: name, UID: types.UID(uid), Controller: &isController}}
+	}
+	rs := &appsv1.ReplicaSet{ObjectMeta: metav1.ObjectMeta{
+		Name: "web-5d8f", Namespace: "default", UID: "rs-uid", Labels: labels,
+		OwnerReferences: controller("Deployment", "web", "deploy-uid"),
+	}}
+	pod := func(name string, status corev1.ContainerStatus) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "default", Labels: labels,
+				OwnerReferences: controller("ReplicaSet", "web-5d8f", "rs-uid"),
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{status}},
+		}
+	}
+	crashing := pod("web-5d8f-crash", corev1.ContainerStatus{
+		Name:                 "web",
+		RestartCount:         3,
+		State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+	})
+	pulling := pod("web-5d8f-pull", corev1.ContainerStatus{
+		Name:  "web",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}},
+	})
+	healthy := pod("web-5d8f-ok", corev1.ContainerStatus{Name: "web", Ready: true})
+
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	event := func(name, kind, object, reason string, age time.Duration, eventType string) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object},
+			Reason:         reason,
+			Message:        reason + " message",
+			Type:           eventType,
+			Count:          2,
+			LastTimestamp:  metav1.NewTime(now.Add(-age)),
+		}
+	}
+
+	clientset := fake.NewSimpleClientset(deploy, rs, crashing, pulling, healthy,
+		event("e1", "Pod", "web-5d8f-crash", "BackOff", time.Minute, corev1.EventTypeWarning),
+		event("e2", "ReplicaSet", "web-5d8f", "FailedCreate", 2*time.Minute, corev1.EventTypeWarning),
+		event("e3", "Pod", "web-5d8f-crash", "Pulled", time.Minute, corev1.EventTypeNormal),
+		event("e4", "Pod", "api-1", "BackOff", time.Minute, corev1.EventTypeWarning),
+	)
+
+	got := collectDiagnosis(context.Background(), clientset, &objectError{
+		Kind: "Deployment", Namespace: "default", Name: "web",
+	}, 10)
+	want := `Warning events:
+  2024-01-02T15:02:05Z ReplicaSet/web-5d8f FailedCreate: FailedCreate message (x2)
+  2024-01-02T15:03:05Z Pod/web-5d8f-crash BackOff: BackOff message (x2)
+Pod web-5d8f-crash: Running
+  web: waiting CrashLoopBackOff, 3 restarts, last terminated OOMKilled (exit code 137)
+  Logs of web (previous container, last 10 lines):
+    fake logs
+Pod web-5d8f-pull: Running
+  web: waiting ImagePullBackOff
+`
+	if got != want {
+		t.Errorf("collectDiagnosis()\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// the diagnosis of a missing workload still reports the error
+	got = collectDiagnosis(context.Background(), clientset, &objectError{
+		Kind: "StatefulSet", Namespace: "default", Name: "db",
+	}, 10)
+	if !strings.HasPrefix(got, "get StatefulSet/db failed") || !strings.Contains(got, "No warning events.") {
+		t.Errorf("collectDiagnosis() = %q", got)
+	}
+}
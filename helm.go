@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/appleboy/deploy-k8s/template"
+
+	"github.com/rs/zerolog/log"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// helm release history limit, the same default as helm upgrade
+const helmMaxHistory = 10
+
+// setHelmOwnership adds the metadata helm uses to adopt the objects of the release.
+func setHelmOwnership(rel *template.ChartRelease) {
+	for _, v := range rel.Objects {
+		labels := v.Obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels["app.kubernetes.io/managed-by"] = "Helm"
+		v.Obj.SetLabels(labels)
+
+		annotations := v.Obj.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations["meta.helm.sh/release-name"] = rel.Name
+		annotations["meta.helm.sh/release-namespace"] = rel.Namespace
+		v.Obj.SetAnnotations(annotations)
+	}
+}
+
+// recordRelease stores the chart release in the helm secrets storage,
+// so helm list and helm history still see the release.
+func (p *Plugin) recordRelease(cfg *rest.Config, rel *template.ChartRelease) error {
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	d := driver.NewSecrets(clientset.CoreV1().Secrets(rel.Namespace))
+	d.Log = func(format string, v ...interface{}) {
+		log.Debug().Msgf(format, v...)
+	}
+	store := storage.Init(d)
+	store.MaxHistory = helmMaxHistory
+
+	history, err := store.History(rel.Name)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return err
+	}
+
+	now := helmtime.Now()
+	version := 1
+	firstDeployed := now
+	description := "Install complete"
+	for _, h := range history {
+		if h.Version >= version {
+			version = h.Version + 1
+		}
+		if h.Info == nil {
+			continue
+		}
+		if h.Info.FirstDeployed.Before(firstDeployed) {
+			firstDeployed = h.Info.FirstDeployed
+		}
+		if h.Info.Status == release.StatusDeployed {
+			description = "Upgrade complete"
+			h.Info.Status = release.StatusSuperseded
+			if err := store.Update(h); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := store.Create(&release.Release{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Chart:     rel.Chart,
+		Config:    rel.Values,
+		Manifest:  rel.Manifest,
+		Hooks:     rel.Hooks,
+		Version:   version,
+		Info: &release.Info{
+			FirstDeployed: firstDeployed,
+			LastDeployed:  now,
+			Status:        release.StatusDeployed,
+			Description:   description,
+		},
+	}); err != nil {
+		return fmt.Errorf("record helm release %s failed: %w", rel.Name, err)
+	}
+
+	log.Info().
+		Str("namespace", rel.Namespace).
+		Str("release", rel.Name).
+		Int("revision", version).
+		Msg("record helm release success")
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// log formats of the log-format flag
+const (
+	logFormatConsole = "console"
+	logFormatJSON    = "json"
+	logFormatGitHub  = "github"
+)
+
+// events of the structured logs, the log pipelines can filter on the event field.
+const (
+	eventApply    = "apply"
+	eventImage    = "image"
+	eventRestart  = "restart"
+	eventScale    = "scale"
+	eventRollout  = "rollout"
+	eventValidate = "validate"
+	eventPolicy   = "policy"
+	eventDiagnose = "diagnose"
+)
+
+// newLogger returns the logger of the log format.
+func newLogger(format string, out io.Writer, color bool) (zerolog.Logger, error) {
+	console := zerolog.ConsoleWriter{
+		Out:     out,
+		NoColor: !color,
+	}
+	switch format {
+	case "", logFormatConsole:
+		return zerolog.New(console).With().Timestamp().Logger(), nil
+	case logFormatJSON:
+		return zerolog.New(out).With().Timestamp().Logger(), nil
+	case logFormatGitHub:
+		return zerolog.New(githubWriter{out: out, console: console}).With().Timestamp().Logger(), nil
+	}
+	return zerolog.Logger{}, fmt.Errorf("invalid log format %q, expected console, json or github", format)
+}
+
+// githubWriter writes the warnings and errors as GitHub Actions annotations
+// and the other logs with the console writer.
+type githubWriter struct {
+	out     io.Writer
+	console zerolog.ConsoleWriter
+}
+
+func (w githubWriter) Write(p []byte) (int, error) {
+	return w.console.Write(p)
+}
+
+func (w githubWriter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	var command string
+	switch level {
+	case zerolog.WarnLevel:
+		command = "warning"
+	case zerolog.ErrorLevel, zerolog.FatalLevel, zerolog.PanicLevel:
+		command = "error"
+	default:
+		return w.console.Write(p)
+	}
+
+	var evt map[string]interface{}
+	if err := json.Unmarshal(p, &evt); err != nil {
+		return w.console.Write(p)
+	}
+	field := func(name string) string {
+		s, _ := evt[name].(string)
+		return s
+	}
+
+	var props []string
+	if file := field("template"); file != "" {
+		props = append(props, "file="+escapeProperty(file))
+	}
+	if kind, name := field("kind"), field("name"); kind != "" && name != "" {
+		props = append(props, "title="+escapeProperty(kind+"/"+name))
+	} else if workload := field("workload"); workload != "" {
+		props = append(props, "title="+escapeProperty(workload))
+	}
+
+	msg := field(zerolog.MessageFieldName)
+	if rule := field("rule"); rule != "" {
+		msg = rule + ": " + msg
+	}
+	if err := field(zerolog.ErrorFieldName); err != "" {
+		if msg == "" {
+			msg = err
+		} else {
+			msg += ": " + err
+		}
+	}
+
+	line := "::" + command
+	if len(props) > 0 {
+		line += " " + strings.Join(props, ",")
+	}
+	line += "::" + escapeData(msg) + "\n"
+	if _, err := io.WriteString(w.out, line); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// escapeData escapes the message of the workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes the property value of the workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := newLogger(logFormatJSON, &buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info().
+		Str("event", eventApply).
+		Str("kind", "Deployment").
+		Str("name", "web").
+		Msg("apply resource success")
+
+	var evt map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &evt); err != nil {
+		t.Fatalf("json log %q: %v", buf.String(), err)
+	}
+	for key, want := range map[string]string{
+		"level":   "info",
+		"event":   "apply",
+		"kind":    "Deployment",
+		"name":    "web",
+		"message": "apply resource success",
+	} {
+		if evt[key] != want {
+			t.Errorf("%s = %v, want %q", key, evt[key], want)
+		}
+	}
+	if _, ok := evt["time"]; !ok {
+		t.Error("time field is missing")
+	}
+
+	if _, err := newLogger("xml", &buf, false); err == nil {
+		t.Error("newLogger(xml) error = nil, want invalid log format")
+	}
+}
+
+func TestGitHubLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := newLogger(logFormatGitHub, &buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Info().Str("kind", "Deployment").Str("name", "web").Msg("apply resource success")
+	l.Warn().
+		Str("template", "deploy/app.yaml").
+		Str("kind", "Deployment").
+		Str("name", "web").
+		Str("rule", "resource-limits").
+		Msg("container web has no cpu limit")
+	l.Error().
+		Str("template", "deploy/a,b.yaml").
+		Str("kind", "Deployment").
+		Str("name", "api").
+		Err(errors.New("field is immutable\nspec.selector")).
+		Msg("apply resource failed")
+	l.Error().
+		Str("workload", "deployment/web").
+		Msg("100% failed")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "INF") || !strings.Contains(lines[0], "apply resource success") {
+		t.Errorf("info line = %q, want console output", lines[0])
+	}
+	want := []string{
+		"::warning file=deploy/app.yaml,title=Deployment/web::resource-limits: container web has no cpu limit",
+		"::error file=deploy/a%2Cb.yaml,title=Deployment/api::apply resource failed: field is immutable%0Aspec.selector",
+		"::error title=deployment/web::100%25 failed",
+	}
+	for i, w := range want {
+		if lines[i+1] != w {
+			t.Errorf("line %d = %q, want %q", i+1, lines[i+1], w)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/appleboy/deploy-k8s/config"
+	"github.com/appleboy/deploy-k8s/validate"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/joho/godotenv"
@@ -27,14 +28,8 @@ func main() {
 		_ = godotenv.Load(filename)
 	}
 
-	isTerm := isatty.IsTerminal(os.Stdout.Fd())
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	log.Logger = log.Output(
-		zerolog.ConsoleWriter{
-			Out:     os.Stderr,
-			NoColor: !isTerm,
-		},
-	)
+	log.Logger, _ = newLogger(logFormatConsole, os.Stderr, isatty.IsTerminal(os.Stdout.Fd()))
 	zerolog.CallerMarshalFunc = func(pc uintptr, file string, line int) string {
 		short := file
 		for i := len(file) - 1; i > 0; i-- {
@@ -80,16 +75,121 @@ func main() {
 			Usage:   "kubernetes service account token",
 			EnvVars: []string{"PLUGIN_TOKEN", "INPUT_TOKEN"},
 		},
+		&cli.StringFlag{
+			Name:    "age-key",
+			Usage:   "age identities to decrypt sops encrypted templates",
+			EnvVars: []string{"PLUGIN_AGE_KEY", "INPUT_AGE_KEY", "SOPS_AGE_KEY"},
+		},
 		&cli.StringFlag{
 			Name:    "namespace",
 			Usage:   "kubernetes namespace",
 			EnvVars: []string{"PLUGIN_NAMESPACE", "INPUT_NAMESPACE"},
 		},
+		&cli.StringFlag{
+			Name:    "action",
+			Usage:   "deploy, restart, render or validate",
+			EnvVars: []string{"PLUGIN_ACTION", "INPUT_ACTION"},
+			Value:   "deploy",
+		},
+		&cli.StringFlag{
+			Name:    "render-output",
+			Usage:   "write the rendered objects to the directory instead of stdout",
+			EnvVars: []string{"PLUGIN_RENDER_OUTPUT", "INPUT_RENDER_OUTPUT"},
+		},
+		&cli.StringFlag{
+			Name:    "render-format",
+			Usage:   "format of the rendered objects, yaml or json",
+			EnvVars: []string{"PLUGIN_RENDER_FORMAT", "INPUT_RENDER_FORMAT"},
+			Value:   "yaml",
+		},
+		&cli.BoolFlag{
+			Name:    "skip-unchanged",
+			Usage:   "skip applying the objects unchanged by a server-side dry-run",
+			EnvVars: []string{"PLUGIN_SKIP_UNCHANGED", "INPUT_SKIP_UNCHANGED"},
+		},
+		&cli.Int64Flag{
+			Name:    "diagnose-log-lines",
+			Usage:   "container log lines printed when an apply or rollout fails, 0 to skip the logs",
+			EnvVars: []string{"PLUGIN_DIAGNOSE_LOG_LINES", "INPUT_DIAGNOSE_LOG_LINES"},
+			Value:   20,
+		},
+		&cli.StringSliceFlag{
+			Name:    "report",
+			Usage:   "write the deploy report to the files, .json, .xml (JUnit) or .md (Markdown)",
+			EnvVars: []string{"PLUGIN_REPORT", "INPUT_REPORT"},
+		},
+		&cli.BoolFlag{
+			Name:    "report-summary",
+			Usage:   "append the Markdown deploy report to $GITHUB_STEP_SUMMARY",
+			EnvVars: []string{"PLUGIN_REPORT_SUMMARY", "INPUT_REPORT_SUMMARY"},
+		},
+		&cli.BoolFlag{
+			Name:    "validate",
+			Usage:   "validate the objects against the OpenAPI schemas before apply",
+			EnvVars: []string{"PLUGIN_VALIDATE", "INPUT_VALIDATE"},
+		},
+		&cli.StringFlag{
+			Name:    "kube-version",
+			Usage:   "kubernetes version of the OpenAPI schemas to download, like v1.30.0, the v1.29 schemas are bundled",
+			EnvVars: []string{"PLUGIN_KUBE_VERSION", "INPUT_KUBE_VERSION"},
+			Value:   validate.BundledVersion,
+		},
+		&cli.StringFlag{
+			Name:    "schema-location",
+			Usage:   "local directory with the OpenAPI v3 schemas, like apis__apps__v1_openapi.json",
+			EnvVars: []string{"PLUGIN_SCHEMA_LOCATION", "INPUT_SCHEMA_LOCATION"},
+		},
+		&cli.StringFlag{
+			Name:    "schema-cache",
+			Usage:   "cache directory of the downloaded OpenAPI schemas",
+			EnvVars: []string{"PLUGIN_SCHEMA_CACHE", "INPUT_SCHEMA_CACHE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "crd-schemas",
+			Usage:   "custom resource definition files used to validate the custom resources",
+			EnvVars: []string{"PLUGIN_CRD_SCHEMAS", "INPUT_CRD_SCHEMAS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "policy",
+			Usage:   "built-in policy rules checked before apply: latest-tag, privileged, host-path, allowed-registries or resource-limits, name=warning to only warn",
+			EnvVars: []string{"PLUGIN_POLICY", "INPUT_POLICY"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "allowed-registries",
+			Usage:   "registries or registry paths allowed by the allowed-registries policy rule",
+			EnvVars: []string{"PLUGIN_ALLOWED_REGISTRIES", "INPUT_ALLOWED_REGISTRIES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "policy-files",
+			Usage:   "YAML files of CEL policy rules checked before apply",
+			EnvVars: []string{"PLUGIN_POLICY_FILES", "INPUT_POLICY_FILES"},
+		},
+		&cli.BoolFlag{
+			Name:    "wait",
+			Usage:   "wait for the rollout or scale of the workloads to finish",
+			EnvVars: []string{"PLUGIN_WAIT", "INPUT_WAIT"},
+		},
+		&cli.DurationFlag{
+			Name:    "wait-timeout",
+			Usage:   "timeout for waiting the rollout",
+			EnvVars: []string{"PLUGIN_WAIT_TIMEOUT", "INPUT_WAIT_TIMEOUT"},
+			Value:   5 * time.Minute,
+		},
 		&cli.StringSliceFlag{
 			Name:    "deployment",
-			Usage:   "Name of the Kubernetes deployment to update",
+			Usage:   "Name of the Kubernetes deployment to update, use kind/name for statefulset or daemonset on restart or scale",
 			EnvVars: []string{"PLUGIN_DEPLOYMENT", "INPUT_DEPLOYMENT"},
 		},
+		&cli.StringFlag{
+			Name:    "selector",
+			Usage:   "label selector of the workloads to restart or scale",
+			EnvVars: []string{"PLUGIN_SELECTOR", "INPUT_SELECTOR"},
+		},
+		&cli.StringFlag{
+			Name:    "replicas",
+			Usage:   "scale the workloads to the replicas, support N, +N or -N",
+			EnvVars: []string{"PLUGIN_REPLICAS", "INPUT_REPLICAS"},
+		},
 		&cli.StringSliceFlag{
 			Name:    "container",
 			Usage:   "Name of the container within the deployment to update",
@@ -100,6 +200,41 @@ func main() {
 			Usage:   "New image and tag for the container",
 			EnvVars: []string{"PLUGIN_IMAGE", "INPUT_IMAGE"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "set-env",
+			Usage:   "set env variable of the container within the deployment, format: container:KEY=VALUE",
+			EnvVars: []string{"PLUGIN_SET_ENV", "INPUT_SET_ENV"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "unset-env",
+			Usage:   "remove env variable from the container within the deployment, format: container:KEY",
+			EnvVars: []string{"PLUGIN_UNSET_ENV", "INPUT_UNSET_ENV"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "set-resources",
+			Usage:   "set resource limits or requests of the container, format: container:limits.cpu=500m",
+			EnvVars: []string{"PLUGIN_SET_RESOURCES", "INPUT_SET_RESOURCES"},
+		},
+		&cli.BoolFlag{
+			Name:    "record",
+			Usage:   "record change-cause, commit SHA, build URL and actor annotations on updated workloads",
+			EnvVars: []string{"PLUGIN_RECORD", "INPUT_RECORD"},
+		},
+		&cli.StringFlag{
+			Name:    "change-cause",
+			Usage:   "custom kubernetes.io/change-cause annotation, support template",
+			EnvVars: []string{"PLUGIN_CHANGE_CAUSE", "INPUT_CHANGE_CAUSE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "annotations",
+			Usage:   "extra key=value annotations on updated workloads, support template",
+			EnvVars: []string{"PLUGIN_ANNOTATIONS", "INPUT_ANNOTATIONS"},
+		},
+		&cli.BoolFlag{
+			Name:    "config-checksum",
+			Usage:   "stamp the checksum of the referenced ConfigMaps and Secrets on the pod template of the workloads",
+			EnvVars: []string{"PLUGIN_CONFIG_CHECKSUM", "INPUT_CONFIG_CHECKSUM"},
+		},
 		&cli.StringFlag{
 			Name:    "proxy-url",
 			Usage:   "URLs with http, https, and socks5",
@@ -107,9 +242,86 @@ func main() {
 		},
 		&cli.StringSliceFlag{
 			Name:    "templates",
-			Usage:   "template files, support glob pattern",
+			Usage:   "template files, directories, glob patterns (support **), - for stdin or http(s) URLs",
 			EnvVars: []string{"PLUGIN_TEMPLATES", "INPUT_TEMPLATES"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "template-include",
+			Usage:   "patterns of the files loaded from template directories",
+			Value:   cli.NewStringSlice("*.yaml", "*.yml", "*.json", "*.jsonnet", "_*.tpl"),
+			EnvVars: []string{"PLUGIN_TEMPLATE_INCLUDE", "INPUT_TEMPLATE_INCLUDE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "template-exclude",
+			Usage:   "patterns of the files and directories skipped in template directories",
+			EnvVars: []string{"PLUGIN_TEMPLATE_EXCLUDE", "INPUT_TEMPLATE_EXCLUDE"},
+		},
+		&cli.BoolFlag{
+			Name:    "allow-missing-templates",
+			Usage:   "skip template patterns matching no files instead of failing",
+			EnvVars: []string{"PLUGIN_ALLOW_MISSING_TEMPLATES", "INPUT_ALLOW_MISSING_TEMPLATES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "env-profile",
+			Usage:   "CI provider profiles exposed as .envs: drone, github, gitlab, woodpecker, jenkins or buildkite",
+			Value:   cli.NewStringSlice("drone", "github"),
+			EnvVars: []string{"PLUGIN_ENV_PROFILE", "INPUT_ENV_PROFILE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "env-prefix",
+			Usage:   "environment variable prefixes exposed as .envs without the prefix, takes precedence over the profiles",
+			EnvVars: []string{"PLUGIN_ENV_PREFIX", "INPUT_ENV_PREFIX"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "env-allow",
+			Usage:   "environment variable names or glob patterns exposed as .envs",
+			EnvVars: []string{"PLUGIN_ENV_ALLOW", "INPUT_ENV_ALLOW"},
+		},
+		&cli.BoolFlag{
+			Name:    "env-typed",
+			Usage:   "parse the JSON, number and bool values of the plugin settings, and __ separated names as nested maps",
+			EnvVars: []string{"PLUGIN_ENV_TYPED", "INPUT_ENV_TYPED"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "kustomize",
+			Usage:   "kustomization directories to build and apply",
+			EnvVars: []string{"PLUGIN_KUSTOMIZE", "INPUT_KUSTOMIZE"},
+		},
+		&cli.StringFlag{
+			Name:    "chart",
+			Usage:   "local helm chart directory or archive to render and apply",
+			EnvVars: []string{"PLUGIN_CHART", "INPUT_CHART"},
+		},
+		&cli.StringFlag{
+			Name:    "chart-release",
+			Usage:   "helm release name, default is the chart name",
+			EnvVars: []string{"PLUGIN_CHART_RELEASE", "INPUT_CHART_RELEASE"},
+		},
+		&cli.BoolFlag{
+			Name:    "chart-record-release",
+			Usage:   "record the helm release secret, so helm list still sees the release",
+			EnvVars: []string{"PLUGIN_CHART_RECORD_RELEASE", "INPUT_CHART_RECORD_RELEASE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "template-helpers",
+			Usage:   "template helper files with shared define blocks, support glob pattern",
+			EnvVars: []string{"PLUGIN_TEMPLATE_HELPERS", "INPUT_TEMPLATE_HELPERS"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "values",
+			Usage:   "values files exposed as .Values in templates, merged in order",
+			EnvVars: []string{"PLUGIN_VALUES", "INPUT_VALUES"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "set",
+			Usage:   "override values on the command line, format: key.path=value",
+			EnvVars: []string{"PLUGIN_SET", "INPUT_SET"},
+		},
+		&cli.BoolFlag{
+			Name:    "strict",
+			Usage:   "return an error when a template refers to a variable which is not defined",
+			EnvVars: []string{"PLUGIN_STRICT", "INPUT_STRICT"},
+		},
 		&cli.StringFlag{
 			Name:    "output",
 			Usage:   "Generate Kubernetes config file",
@@ -138,6 +350,12 @@ func main() {
 			Usage:   "enable debug mode",
 			EnvVars: []string{"PLUGIN_DEBUG", "INPUT_DEBUG"},
 		},
+		&cli.StringFlag{
+			Name:    "log-format",
+			Usage:   "log format, console, json or github",
+			EnvVars: []string{"PLUGIN_LOG_FORMAT", "INPUT_LOG_FORMAT"},
+			Value:   "console",
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -146,6 +364,11 @@ func main() {
 }
 
 func run(c *cli.Context) error {
+	logger, err := newLogger(c.String("log-format"), os.Stderr, isatty.IsTerminal(os.Stdout.Fd()))
+	if err != nil {
+		return err
+	}
+	log.Logger = logger
 	if c.Bool("debug") {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 		log.Logger = log.With().Caller().Logger()
@@ -153,28 +376,71 @@ func run(c *cli.Context) error {
 
 	plugin := &Plugin{
 		Config: &config.K8S{
-			Server:       c.String("server"),
-			SkipTLS:      c.Bool("skip-tls"),
-			CaCert:       c.String("ca-cert"),
-			Namespace:    c.String("namespace"),
-			Deployment:   c.StringSlice("deployment"),
-			Container:    c.StringSlice("container"),
-			Image:        c.String("image"),
-			ProxyURL:     c.String("proxy-url"),
-			Templates:    c.StringSlice("templates"),
-			Output:       c.String("output"),
-			ClusterName:  c.String("cluster-name"),
-			AuthInfoName: c.String("authinfo-name"),
-			ContextName:  c.String("context-name"),
-			Debug:        c.Bool("debug"),
+			Server:                c.String("server"),
+			SkipTLS:               c.Bool("skip-tls"),
+			CaCert:                c.String("ca-cert"),
+			Namespace:             c.String("namespace"),
+			Action:                c.String("action"),
+			RenderOutput:          c.String("render-output"),
+			RenderFormat:          c.String("render-format"),
+			SkipUnchanged:         c.Bool("skip-unchanged"),
+			DiagnoseLogLines:      c.Int64("diagnose-log-lines"),
+			Report:                c.StringSlice("report"),
+			ReportSummary:         c.Bool("report-summary"),
+			Validate:              c.Bool("validate"),
+			KubeVersion:           c.String("kube-version"),
+			SchemaLocation:        c.String("schema-location"),
+			SchemaCache:           c.String("schema-cache"),
+			CRDSchemas:            c.StringSlice("crd-schemas"),
+			Policy:                c.StringSlice("policy"),
+			AllowedRegistries:     c.StringSlice("allowed-registries"),
+			PolicyFiles:           c.StringSlice("policy-files"),
+			Wait:                  c.Bool("wait"),
+			WaitTimeout:           c.Duration("wait-timeout"),
+			Deployment:            c.StringSlice("deployment"),
+			Selector:              c.String("selector"),
+			Replicas:              c.String("replicas"),
+			Container:             c.StringSlice("container"),
+			Image:                 c.String("image"),
+			SetEnv:                c.StringSlice("set-env"),
+			UnsetEnv:              c.StringSlice("unset-env"),
+			SetResources:          c.StringSlice("set-resources"),
+			Record:                c.Bool("record"),
+			ChangeCause:           c.String("change-cause"),
+			Annotations:           c.StringSlice("annotations"),
+			ConfigChecksum:        c.Bool("config-checksum"),
+			ProxyURL:              c.String("proxy-url"),
+			Templates:             c.StringSlice("templates"),
+			TemplateHelpers:       c.StringSlice("template-helpers"),
+			AllowMissingTemplates: c.Bool("allow-missing-templates"),
+			TemplateInclude:       c.StringSlice("template-include"),
+			TemplateExclude:       c.StringSlice("template-exclude"),
+			EnvProfile:            c.StringSlice("env-profile"),
+			EnvPrefix:             c.StringSlice("env-prefix"),
+			EnvAllow:              c.StringSlice("env-allow"),
+			EnvTyped:              c.Bool("env-typed"),
+			Kustomize:             c.StringSlice("kustomize"),
+			Chart:                 c.String("chart"),
+			ChartRelease:          c.String("chart-release"),
+			ChartRecordRelease:    c.Bool("chart-record-release"),
+			Values:                c.StringSlice("values"),
+			Set:                   c.StringSlice("set"),
+			Strict:                c.Bool("strict"),
+			Output:                c.String("output"),
+			ClusterName:           c.String("cluster-name"),
+			AuthInfoName:          c.String("authinfo-name"),
+			ContextName:           c.String("context-name"),
+			Debug:                 c.Bool("debug"),
+			LogFormat:             c.String("log-format"),
 		},
 		AuthInfo: &config.AuthInfo{
-			Token: c.String("token"),
+			Token:  c.String("token"),
+			AgeKey: c.String("age-key"),
 		},
 	}
 
 	if plugin.Config.Debug {
-		spew.Dump(plugin)
+		spew.Fdump(os.Stderr, plugin.redacted())
 	}
 
 	return plugin.Exec()
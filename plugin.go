@@ -3,18 +3,23 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/appleboy/deploy-k8s/config"
 	"github.com/appleboy/deploy-k8s/kube"
+	"github.com/appleboy/deploy-k8s/redact"
+	"github.com/appleboy/deploy-k8s/report"
 	"github.com/appleboy/deploy-k8s/template"
 
 	"github.com/appleboy/com/array"
 	"github.com/davecgh/go-spew/spew"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
 	memory "k8s.io/client-go/discovery/cached"
 	"k8s.io/client-go/dynamic"
@@ -29,10 +34,25 @@ type (
 	Plugin struct {
 		Config   *config.K8S
 		AuthInfo *config.AuthInfo
+
+		// rendered helm chart, set by Objects
+		chart *template.ChartRelease
+		// report of the deploy, set by Exec
+		report *report.Report
 	}
 )
 
 func (p *Plugin) Exec() error {
+	p.addMasks()
+
+	// render or validate the templates without the cluster
+	switch p.Config.Action {
+	case "render":
+		return p.Render()
+	case "validate":
+		return p.Validate()
+	}
+
 	if p.Config.Server == "" {
 		return fmt.Errorf("server is required")
 	}
@@ -54,22 +74,142 @@ func (p *Plugin) Exec() error {
 		return nil
 	}
 
+	for _, file := range p.Config.Report {
+		if err := report.CheckFile(file); err != nil {
+			return err
+		}
+	}
+	action := p.Config.Action
+	if action == "" {
+		action = "deploy"
+	}
+	p.report = report.New(action, p.Config.Namespace)
+	return p.writeReport(p.exec())
+}
+
+// exec runs the action with the cluster, and diagnoses the failed apply or rollout.
+func (p *Plugin) exec() error {
 	restConfig, err := kube.NewRestConfig(p.Config, p.AuthInfo)
 	if err != nil {
 		return err
 	}
 
-	if err := p.Apply(restConfig); err != nil {
+	if err := p.run(restConfig); err != nil {
+		p.diagnose(restConfig, err)
 		return err
 	}
+	return nil
+}
 
-	if err := p.UpdateContainer(restConfig); err != nil {
-		return err
+// run runs the deploy or restart action.
+func (p *Plugin) run(restConfig *rest.Config) error {
+	switch p.Config.Action {
+	case "", "deploy":
+		if err := p.Apply(restConfig); err != nil {
+			return err
+		}
+
+		if err := p.UpdateContainer(restConfig); err != nil {
+			return err
+		}
+
+		if err := p.Scale(restConfig); err != nil {
+			return err
+		}
+	case "restart":
+		if err := p.Restart(restConfig); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported action: %s", p.Config.Action)
 	}
 
 	return nil
 }
 
+// environment returns the environment variables exposed as .envs in the templates.
+func (p *Plugin) environment() (map[string]any, error) {
+	return template.LoadEnvironment(template.EnvConfig{
+		Profiles: p.Config.EnvProfile,
+		Prefixes: p.Config.EnvPrefix,
+		Allow:    p.Config.EnvAllow,
+		Typed:    p.Config.EnvTyped,
+	})
+}
+
+// Objects renders the templates, kustomizations and helm chart, and returns the objects to apply.
+func (p *Plugin) Objects() ([]*template.KubeObject, error) {
+	allenvs, err := p.environment()
+	if err != nil {
+		return nil, err
+	}
+	if p.Config.Debug {
+		spew.Fdump(os.Stderr, redact.Env(allenvs))
+	}
+	values, err := template.LoadValues(p.Config.Values, p.Config.Set)
+	if err != nil {
+		return nil, err
+	}
+	kubeObjs, err := template.ParseSet(
+		p.Config.Templates, allenvs,
+		template.WithValues(values),
+		template.WithStrict(p.Config.Strict),
+		template.WithHelpers(p.Config.TemplateHelpers...),
+		template.WithAgeKey(p.AuthInfo.AgeKey),
+		template.WithAllowMissing(p.Config.AllowMissingTemplates),
+		template.WithInclude(p.Config.TemplateInclude...),
+		template.WithExclude(p.Config.TemplateExclude...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dir := range p.Config.Kustomize {
+		objs, err := template.ParseKustomization(dir)
+		if err != nil {
+			return nil, err
+		}
+		kubeObjs = append(kubeObjs, objs...)
+	}
+
+	if p.Config.Chart != "" {
+		if p.Config.ChartRecordRelease && p.Config.Namespace == "" {
+			return nil, fmt.Errorf("namespace is required to record helm release")
+		}
+		rel, err := template.ParseChart(p.Config.Chart, p.Config.ChartRelease, p.Config.Namespace, values)
+		if err != nil {
+			return nil, err
+		}
+		if p.Config.ChartRecordRelease {
+			setHelmOwnership(rel)
+		}
+		p.chart = rel
+		kubeObjs = append(append(kubeObjs, rel.CRDs...), rel.Objects...)
+	}
+
+	annotations, err := p.deployAnnotations(allenvs)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range kubeObjs {
+		if isWorkload(v.GVK) {
+			if err := setWorkloadAnnotations(v.Obj, annotations); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if p.Config.ConfigChecksum {
+		if err := setConfigChecksum(kubeObjs, p.Config.Namespace); err != nil {
+			return nil, err
+		}
+	}
+
+	p.addObjectMasks(allenvs, kubeObjs)
+
+	return kubeObjs, nil
+}
+
 func (p *Plugin) Apply(cfg *rest.Config) error {
 	dyn, err := dynamic.NewForConfig(cfg)
 	if err != nil {
@@ -82,15 +222,21 @@ func (p *Plugin) Apply(cfg *rest.Config) error {
 	}
 	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc))
 
-	allenvs := template.GetAllEnviroment()
-	if p.Config.Debug {
-		spew.Dump(allenvs)
-	}
-	kubeObjs, err := template.ParseSet(p.Config.Templates, allenvs)
+	kubeObjs, err := p.Objects()
 	if err != nil {
 		return err
 	}
 
+	if p.Config.Validate {
+		if err := p.validate(kubeObjs); err != nil {
+			return err
+		}
+	}
+
+	if err := p.checkPolicies(kubeObjs); err != nil {
+		return err
+	}
+
 	for _, v := range kubeObjs {
 		mapping, err := mapper.RESTMapping(v.GVK.GroupKind(), v.GVK.Version)
 		if err != nil {
@@ -118,44 +264,109 @@ func (p *Plugin) Apply(cfg *rest.Config) error {
 			dr = dyn.Resource(mapping.Resource)
 		}
 
-		obj, err := dr.Apply(
-			context.Background(),
-			v.Obj.GetName(),
-			v.Obj,
-			metav1.ApplyOptions{
-				FieldManager: "deploy-k8s-plugin",
-				Force:        true,
-			},
-		)
-		if err != nil {
-			return err
-		}
-
 		l := log.With().
+			Str("event", eventApply).
+			Str("template", v.TplPath).
 			Str("apiVersion", v.GVK.GroupVersion().String()).
 			Str("kind", v.GVK.Kind).
-			Str("namespace", obj.GetNamespace()).
-			Str("name", obj.GetName()).
+			Str("namespace", v.Obj.GetNamespace()).
+			Str("name", v.Obj.GetName()).
 			Logger()
 
-		if p.Config.Debug {
+		start := time.Now()
+		action, err := p.applyObject(dr, v)
+		p.report.AddObject(&report.Object{
+			Template:   v.TplPath,
+			APIVersion: v.GVK.GroupVersion().String(),
+			Kind:       v.GVK.Kind,
+			Namespace:  v.Obj.GetNamespace(),
+			Name:       v.Obj.GetName(),
+			Action:     action,
+		}, start, err)
+		if err != nil {
+			l.Error().Err(err).Msg("apply resource failed")
+			return &objectError{
+				Kind:      v.GVK.Kind,
+				Namespace: v.Obj.GetNamespace(),
+				Name:      v.Obj.GetName(),
+				Err:       err,
+			}
+		}
+
+		// the custom resources of the CRD are mapped after it is served
+		if isCRD(v.GVK) {
+			if err := waitForCRD(context.Background(), dr, v.Obj.GetName()); err != nil {
+				l.Error().Err(err).Msg("apply resource failed")
+				return err
+			}
+			mapper.Reset()
+		}
+
+		if p.Config.Debug && !v.Sensitive {
 			l.Debug().
-				Str("template", v.TplPath).
 				Msg("show resource")
-			fmt.Printf("%s", v.PrettyString())
+			fmt.Fprintf(os.Stderr, "%s", v.PrettyString())
 		}
 
 		l.Info().
-			Msg("apply resource success")
+			Str("action", string(action)).
+			Msg("apply resource " + string(action))
+	}
+
+	if p.chart != nil && p.Config.ChartRecordRelease {
+		if err := p.recordRelease(cfg, p.chart); err != nil {
+			return err
+		}
 	}
+
 	return nil
 }
 
-// Update kubernetes deployment container image
+// applyObject applies the object and returns whether it was created, configured or unchanged.
+// With skip unchanged, a dry-run apply is compared with the live object first, and the
+// unchanged object is not applied.
+func (p *Plugin) applyObject(dr dynamic.ResourceInterface, v *template.KubeObject) (report.Action, error) {
+	ctx := context.Background()
+	opts := metav1.ApplyOptions{
+		FieldManager: "deploy-k8s-plugin",
+		Force:        true,
+	}
+
+	live, err := dr.Get(ctx, v.Obj.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		return "", err
+	}
+
+	if live != nil && p.Config.SkipUnchanged {
+		dryRunOpts := opts
+		dryRunOpts.DryRun = []string{metav1.DryRunAll}
+		dryRun, err := dr.Apply(ctx, v.Obj.GetName(), v.Obj, dryRunOpts)
+		if err != nil {
+			return "", err
+		}
+		if sameObject(live, dryRun) {
+			return report.ActionUnchanged, nil
+		}
+	}
+
+	applied, err := dr.Apply(ctx, v.Obj.GetName(), v.Obj, opts)
+	if err != nil {
+		return "", err
+	}
+	return applyAction(live, applied), nil
+}
+
+// UpdateContainer updates the image, env and resources of the containers in the workloads,
+// the deployment names are in the [kind/]name format.
 func (p *Plugin) UpdateContainer(cfg *rest.Config) error {
+	updateImage := len(p.Config.Container) > 0 && p.Config.Image != ""
 	if len(p.Config.Deployment) == 0 ||
-		len(p.Config.Container) == 0 ||
-		p.Config.Image == "" {
+		(!updateImage &&
+			len(p.Config.SetEnv) == 0 &&
+			len(p.Config.UnsetEnv) == 0 &&
+			len(p.Config.SetResources) == 0) {
 		return nil
 	}
 
@@ -163,75 +374,287 @@ func (p *Plugin) UpdateContainer(cfg *rest.Config) error {
 	if err != nil {
 		return err
 	}
+	return p.updateContainers(context.Background(), dyn)
+}
 
-	// update deployment container image
-	deploymentRes := schema.GroupVersionResource{
-		Group:    "apps",
-		Version:  "v1",
-		Resource: "deployments",
+// updateContainers updates the containers of the workloads with the dynamic client.
+func (p *Plugin) updateContainers(ctx context.Context, dyn dynamic.Interface) error {
+	updateImage := len(p.Config.Container) > 0 && p.Config.Image != ""
+	setEnvs, err := parseContainerSettings(p.Config.SetEnv, true)
+	if err != nil {
+		return err
+	}
+	unsetEnvs, err := parseContainerSettings(p.Config.UnsetEnv, false)
+	if err != nil {
+		return err
+	}
+	setResources, err := parseResourceSettings(p.Config.SetResources)
+	if err != nil {
+		return err
+	}
+
+	workloads := make([]workload, 0, len(p.Config.Deployment))
+	for _, v := range p.Config.Deployment {
+		w, err := parseWorkload(v)
+		if err != nil {
+			return err
+		}
+		workloads = append(workloads, w)
+	}
+
+	envs, err := p.environment()
+	if err != nil {
+		return err
+	}
+	annotations, err := p.deployAnnotations(envs)
+	if err != nil {
+		return err
 	}
 
 	l := log.With().
 		Str("namespace", p.Config.Namespace).
 		Logger()
 
-	for _, deployment := range p.Config.Deployment {
-		tryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-			result, err := dyn.Resource(deploymentRes).
-				Namespace(p.Config.Namespace).
-				Get(context.Background(), deployment, metav1.GetOptions{})
-			if err != nil {
-				return err
-			}
-			containers, found, err := unstructured.NestedSlice(result.Object, "spec", "template", "spec", "containers")
-			if err != nil || !found || containers == nil {
-				return fmt.Errorf("deployment containers not found or error in spec: %v", err)
-			}
-			for index, container := range containers {
-				maps := container.(map[string]interface{})
-				if !array.InSlice(maps["name"].(string), p.Config.Container) {
+	// the containers found in any workload
+	matched := map[string]bool{}
+	settings := append(append(append([]containerSetting{}, setEnvs...), unsetEnvs...), setResources...)
+
+	get := func(w workload) (*unstructured.Unstructured, error) {
+		return dyn.Resource(w.Resource).
+			Namespace(p.Config.Namespace).
+			Get(ctx, w.Name, metav1.GetOptions{})
+	}
+
+	// modify changes the containers of the fetched workload, it returns the updated images
+	// and the names of the containers in the workload.
+	modify := func(w workload, result *unstructured.Unstructured, l zerolog.Logger) ([]*report.Image, map[string]bool, error) {
+		var images []*report.Image
+		containers, found, err := unstructured.NestedSlice(result.Object, "spec", "template", "spec", "containers")
+		if err != nil || !found || containers == nil {
+			return nil, nil, fmt.Errorf("%s containers not found or error in spec: %v", w, err)
+		}
+		names := map[string]bool{}
+		for index, container := range containers {
+			maps := container.(map[string]interface{})
+			name := maps["name"].(string)
+			names[name] = true
+
+			if updateImage {
+				if !array.InSlice(name, p.Config.Container) {
 					l.Warn().
-						Str("deployment", deployment).
-						Str("container", maps["name"].(string)).
+						Str("workload", w.String()).
+						Str("container", name).
 						Str("image", p.Config.Image).
-						Msg("container not found in deployment")
+						Msg("container not found in workload")
+				} else {
+					previous, _ := maps["image"].(string)
+					images = append(images, &report.Image{
+						Namespace: p.Config.Namespace,
+						Workload:  w.String(),
+						Container: name,
+						Previous:  previous,
+						Image:     p.Config.Image,
+					})
+					if err := unstructured.SetNestedField(
+						containers[index].(map[string]interface{}),
+						p.Config.Image,
+						"image",
+					); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+
+			for _, s := range setEnvs {
+				if s.Container != name {
 					continue
 				}
+				if err := setContainerEnv(maps, s.Key, s.Value); err != nil {
+					return nil, nil, err
+				}
+			}
 
-				if err := unstructured.SetNestedField(
-					containers[index].(map[string]interface{}),
-					p.Config.Image,
-					"image",
-				); err != nil {
-					return err
+			for _, s := range unsetEnvs {
+				if s.Container != name {
+					continue
+				}
+				if err := unsetContainerEnv(maps, s.Key); err != nil {
+					return nil, nil, err
 				}
+			}
 
-				l.Info().
-					Str("deployment", deployment).
-					Str("container", maps["name"].(string)).
-					Str("image", p.Config.Image).
-					Msg("update deployment container image success")
+			for _, s := range setResources {
+				if s.Container != name {
+					continue
+				}
+				if err := setContainerResource(maps, s.Key, s.Value); err != nil {
+					return nil, nil, err
+				}
 			}
+		}
 
-			if err := unstructured.SetNestedField(
-				result.Object, containers,
-				"spec", "template", "spec", "containers",
-			); err != nil {
-				return err
+		for _, s := range settings {
+			if !names[s.Container] {
+				l.Warn().
+					Str("workload", w.String()).
+					Str("container", s.Container).
+					Str("setting", s.Key).
+					Msg("container not found in workload")
 			}
+		}
+
+		if err := unstructured.SetNestedField(
+			result.Object, containers,
+			"spec", "template", "spec", "containers",
+		); err != nil {
+			return nil, nil, err
+		}
+
+		if err := setWorkloadAnnotations(result, annotations); err != nil {
+			return nil, nil, err
+		}
+		return images, names, nil
+	}
 
-			_, err = dyn.Resource(deploymentRes).
+	// fetch and modify all the workloads first, so nothing is updated
+	// if a setting doesn't match any container
+	type workloadUpdate struct {
+		workload
+		obj    *unstructured.Unstructured
+		images []*report.Image
+		names  map[string]bool
+	}
+	updates := make([]workloadUpdate, 0, len(workloads))
+	for _, w := range workloads {
+		result, err := get(w)
+		if err != nil {
+			return &objectError{
+				Kind:      w.Kind,
+				Namespace: p.Config.Namespace,
+				Name:      w.Name,
+				Err:       fmt.Errorf("get %s failed: %w", w, err),
+			}
+		}
+		images, names, err := modify(w, result, l)
+		if err != nil {
+			return err
+		}
+		for name := range names {
+			matched[name] = true
+		}
+		updates = append(updates, workloadUpdate{workload: w, obj: result, images: images, names: names})
+	}
+
+	for _, s := range settings {
+		if !matched[s.Container] {
+			return fmt.Errorf("container %s of setting %s not found in any workload", s.Container, s.Key)
+		}
+	}
+
+	// the new images and resources must pass the same policies as the applied objects,
+	// all the workloads are checked before the first update
+	engine, err := p.policyEngine()
+	if err != nil {
+		return err
+	}
+	kubeObjs := make([]*template.KubeObject, 0, len(updates))
+	for _, u := range updates {
+		kubeObjs = append(kubeObjs, &template.KubeObject{GVK: u.obj.GroupVersionKind(), Obj: u.obj})
+	}
+	if err := checkPolicyEngine(engine, kubeObjs); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		w, result, images := u.workload, u.obj, u.images
+		refresh := false
+		var policyErr error
+		tryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			// the workload changed since it was fetched, modify the latest version
+			if refresh {
+				latest, err := get(w)
+				if err != nil {
+					return err
+				}
+				if images, _, err = modify(w, latest, zerolog.Nop()); err != nil {
+					return err
+				}
+				policyErr = checkPolicyEngine(engine, []*template.KubeObject{{
+					GVK: latest.GroupVersionKind(),
+					Obj: latest,
+				}})
+				if policyErr != nil {
+					return policyErr
+				}
+				result = latest
+			}
+			refresh = true
+
+			_, err := dyn.Resource(w.Resource).
 				Namespace(p.Config.Namespace).
-				Update(context.TODO(), result, metav1.UpdateOptions{
+				Update(ctx, result, metav1.UpdateOptions{
 					FieldManager: "deploy-k8s-plugin",
 				})
-			if err != nil {
-				return err
-			}
-			return nil
+			return err
 		})
+		// the violations are logged and the workload is unchanged, nothing to diagnose
+		if policyErr != nil {
+			return policyErr
+		}
 		if tryErr != nil {
-			return tryErr
+			return &objectError{
+				Kind:      w.Kind,
+				Namespace: p.Config.Namespace,
+				Name:      w.Name,
+				Err:       fmt.Errorf("update %s failed: %w", w, tryErr),
+			}
+		}
+
+		for _, i := range images {
+			p.report.AddImage(i)
+			l.Info().
+				Str("workload", w.String()).
+				Str("container", i.Container).
+				Str("image", i.Image).
+				Str("event", eventImage).
+				Msg("update container image success")
+		}
+		for _, s := range setEnvs {
+			if u.names[s.Container] {
+				l.Info().
+					Str("workload", w.String()).
+					Str("container", s.Container).
+					Str("env", s.Key).
+					Msg("set container env success")
+			}
+		}
+		for _, s := range unsetEnvs {
+			if u.names[s.Container] {
+				l.Info().
+					Str("workload", w.String()).
+					Str("container", s.Container).
+					Str("env", s.Key).
+					Msg("unset container env success")
+			}
+		}
+		for _, s := range setResources {
+			if u.names[s.Container] {
+				l.Info().
+					Str("workload", w.String()).
+					Str("container", s.Container).
+					Str(s.Key, s.Value).
+					Msg("set container resource success")
+			}
+		}
+	}
+
+	if !p.Config.Wait {
+		return nil
+	}
+
+	for _, w := range workloads {
+		if err := p.waitForRollout(ctx, dyn, w); err != nil {
+			return err
 		}
 	}
 
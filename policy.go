@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/appleboy/deploy-k8s/policy"
+	"github.com/appleboy/deploy-k8s/template"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// policyEngine returns the engine of the policy rules, or nil without any rule.
+func (p *Plugin) policyEngine() (*policy.Engine, error) {
+	if len(p.Config.Policy) == 0 && len(p.Config.PolicyFiles) == 0 {
+		return nil, nil
+	}
+
+	return policy.New(
+		policy.WithRules(p.Config.Policy...),
+		policy.WithAllowedRegistries(p.Config.AllowedRegistries...),
+		policy.WithFiles(p.Config.PolicyFiles...),
+	)
+}
+
+// checkPolicies checks the objects with the policy rules. The warnings are logged,
+// and the errors block the deploy.
+func (p *Plugin) checkPolicies(kubeObjs []*template.KubeObject) error {
+	e, err := p.policyEngine()
+	if err != nil {
+		return err
+	}
+	return checkPolicyEngine(e, kubeObjs)
+}
+
+// checkPolicyEngine checks the objects with an engine built by policyEngine, a nil engine has no rule.
+func checkPolicyEngine(e *policy.Engine, kubeObjs []*template.KubeObject) error {
+	if e == nil {
+		return nil
+	}
+
+	errs := 0
+	for _, v := range e.Check(kubeObjs) {
+		var event *zerolog.Event
+		if v.Severity == policy.SeverityError {
+			event = log.Error()
+			errs++
+		} else {
+			event = log.Warn()
+		}
+		event.
+			Str("event", eventPolicy).
+			Str("template", v.File).
+			Int("document", v.Index).
+			Str("kind", v.GVK.Kind).
+			Str("name", v.Name).
+			Str("rule", v.Rule).
+			Msg(v.Message)
+	}
+	if errs > 0 {
+		return fmt.Errorf("policy check failed: %d violations", errs)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// builtin is a built-in rule, it returns the messages of the violations.
+type builtin func(e *Engine, obj *unstructured.Unstructured) []string
+
+var builtins = map[string]builtin{
+	"latest-tag":         checkLatestTag,
+	"privileged":         checkPrivileged,
+	"host-path":          checkHostPath,
+	"allowed-registries": checkAllowedRegistries,
+	"resource-limits":    checkResourceLimits,
+}
+
+// podSpec returns the pod spec of the pods and the workloads.
+func podSpec(obj *unstructured.Unstructured) (map[string]interface{}, bool) {
+	var fields []string
+	switch obj.GetKind() {
+	case "Pod":
+		fields = []string{"spec"}
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "ReplicationController", "Job":
+		fields = []string{"spec", "template", "spec"}
+	case "CronJob":
+		fields = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	default:
+		return nil, false
+	}
+	spec, found, err := unstructured.NestedMap(obj.Object, fields...)
+	if err != nil || !found {
+		return nil, false
+	}
+	return spec, true
+}
+
+// containers returns the init containers and containers of the pod spec.
+func containers(spec map[string]interface{}) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, field := range []string{"initContainers", "containers"} {
+		items, _, _ := unstructured.NestedSlice(spec, field)
+		for _, item := range items {
+			if c, ok := item.(map[string]interface{}); ok {
+				result = append(result, c)
+			}
+		}
+	}
+	return result
+}
+
+// parseImage returns the normalized repository and the tag or digest of the image,
+// nginx is docker.io/library/nginx.
+func parseImage(image string) (string, string, string) {
+	repo, digest, _ := strings.Cut(image, "@")
+	tag := ""
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo, tag = repo[:i], repo[i+1:]
+	}
+
+	first, _, found := strings.Cut(repo, "/")
+	if !found || (!strings.ContainsAny(first, ".:") && first != "localhost") {
+		if !found {
+			repo = "library/" + repo
+		}
+		repo = "docker.io/" + repo
+	}
+	return repo, tag, digest
+}
+
+func checkLatestTag(_ *Engine, obj *unstructured.Unstructured) []string {
+	spec, ok := podSpec(obj)
+	if !ok {
+		return nil
+	}
+	var msgs []string
+	for _, c := range containers(spec) {
+		image, _ := c["image"].(string)
+		_, tag, digest := parseImage(image)
+		if digest == "" && (tag == "" || tag == "latest") {
+			msgs = append(msgs, fmt.Sprintf("container %s uses the latest tag of image %s", c["name"], image))
+		}
+	}
+	return msgs
+}
+
+func checkPrivileged(_ *Engine, obj *unstructured.Unstructured) []string {
+	spec, ok := podSpec(obj)
+	if !ok {
+		return nil
+	}
+	var msgs []string
+	for _, c := range containers(spec) {
+		if privileged, _, _ := unstructured.NestedBool(c, "securityContext", "privileged"); privileged {
+			msgs = append(msgs, fmt.Sprintf("container %s is privileged", c["name"]))
+		}
+	}
+	return msgs
+}
+
+func checkHostPath(_ *Engine, obj *unstructured.Unstructured) []string {
+	spec, ok := podSpec(obj)
+	if !ok {
+		return nil
+	}
+	var msgs []string
+	volumes, _, _ := unstructured.NestedSlice(spec, "volumes")
+	for _, item := range volumes {
+		v, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if path, found, _ := unstructured.NestedString(v, "hostPath", "path"); found {
+			msgs = append(msgs, fmt.Sprintf("volume %s mounts the host path %s", v["name"], path))
+		}
+	}
+	return msgs
+}
+
+func checkAllowedRegistries(e *Engine, obj *unstructured.Unstructured) []string {
+	spec, ok := podSpec(obj)
+	if !ok {
+		return nil
+	}
+	var msgs []string
+	for _, c := range containers(spec) {
+		image, _ := c["image"].(string)
+		repo, _, _ := parseImage(image)
+		allowed := false
+		for _, registry := range e.registries {
+			registry = strings.TrimSuffix(registry, "/")
+			if repo == registry || strings.HasPrefix(repo, registry+"/") {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			msgs = append(msgs, fmt.Sprintf("container %s uses image %s outside the allowed registries", c["name"], image))
+		}
+	}
+	return msgs
+}
+
+func checkResourceLimits(_ *Engine, obj *unstructured.Unstructured) []string {
+	spec, ok := podSpec(obj)
+	if !ok {
+		return nil
+	}
+	var msgs []string
+	for _, c := range containers(spec) {
+		for _, resource := range []string{"cpu", "memory"} {
+			if _, found, _ := unstructured.NestedFieldNoCopy(c, "resources", "limits", resource); !found {
+				msgs = append(msgs, fmt.Sprintf("container %s has no %s limit", c["name"], resource))
+			}
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,39 @@
+package policy
+
+// Option configures the policy engine.
+type Option func(*options)
+
+type options struct {
+	rules      []string
+	registries []string
+	files      []string
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithRules enables the built-in rules, name or name=warning to report the violations as warnings.
+func WithRules(rules ...string) Option {
+	return func(o *options) {
+		o.rules = append(o.rules, rules...)
+	}
+}
+
+// WithAllowedRegistries sets the registries, or registry paths, allowed by the allowed-registries rule.
+func WithAllowedRegistries(registries ...string) Option {
+	return func(o *options) {
+		o.registries = append(o.registries, registries...)
+	}
+}
+
+// WithFiles loads the CEL rules from the YAML files matching the glob patterns.
+func WithFiles(patterns ...string) Option {
+	return func(o *options) {
+		o.files = append(o.files, patterns...)
+	}
+}
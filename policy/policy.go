@@ -0,0 +1,225 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/appleboy/deploy-k8s/template"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Severity of a rule, violations of error rules block the deploy.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Violation is a rule violated by an object.
+type Violation struct {
+	Rule     string
+	Severity Severity
+	// File is the template which produced the object, and Index the document in the file.
+	File    string
+	Index   int
+	GVK     schema.GroupVersionKind
+	Name    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s[%d] %s/%s: %s: %s", v.File, v.Index, v.GVK.Kind, v.Name, v.Rule, v.Message)
+}
+
+// Engine checks the objects with the built-in rules and the CEL rules.
+type Engine struct {
+	builtins   map[string]Severity
+	registries []string
+	rules      []*celRule
+}
+
+// celRule is a user rule, the expression must be true for the matching objects.
+type celRule struct {
+	Name       string   `json:"name"`
+	Severity   Severity `json:"severity"`
+	Expression string   `json:"expression"`
+	Message    string   `json:"message"`
+	Match      struct {
+		Kinds []string `json:"kinds"`
+	} `json:"match"`
+
+	program cel.Program
+}
+
+// New returns a policy engine.
+func New(opts ...Option) (*Engine, error) {
+	o := newOptions(opts...)
+	e := &Engine{
+		builtins:   map[string]Severity{},
+		registries: o.registries,
+	}
+
+	for _, spec := range o.rules {
+		name, severity, err := parseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := builtins[name]; !ok {
+			return nil, fmt.Errorf("unknown policy rule %q, expected %s", name, strings.Join(builtinNames(), ", "))
+		}
+		e.builtins[name] = severity
+	}
+	if _, ok := e.builtins["allowed-registries"]; ok && len(e.registries) == 0 {
+		return nil, fmt.Errorf("allowed registries are required by the allowed-registries rule")
+	}
+
+	env, err := cel.NewEnv(cel.Variable("object", cel.DynType))
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range o.files {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy files pattern %q: %w", pattern, err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("policy files pattern %q matched no files", pattern)
+		}
+		for _, file := range files {
+			rules, err := loadRules(env, file)
+			if err != nil {
+				return nil, err
+			}
+			e.rules = append(e.rules, rules...)
+		}
+	}
+	return e, nil
+}
+
+// Check checks all the objects and returns the violations in the order of the objects.
+func (e *Engine) Check(objs []*template.KubeObject) []*Violation {
+	var violations []*Violation
+	index := map[string]int{}
+	for _, obj := range objs {
+		i := index[obj.TplPath]
+		index[obj.TplPath]++
+
+		add := func(rule string, severity Severity, msg string) {
+			violations = append(violations, &Violation{
+				Rule:     rule,
+				Severity: severity,
+				File:     obj.TplPath,
+				Index:    i,
+				GVK:      obj.GVK,
+				Name:     obj.Obj.GetName(),
+				Message:  msg,
+			})
+		}
+
+		for _, name := range builtinNames() {
+			severity, ok := e.builtins[name]
+			if !ok {
+				continue
+			}
+			for _, msg := range builtins[name](e, obj.Obj) {
+				add(name, severity, msg)
+			}
+		}
+
+		for _, r := range e.rules {
+			if len(r.Match.Kinds) > 0 && !contains(r.Match.Kinds, obj.GVK.Kind) {
+				continue
+			}
+			out, _, err := r.program.Eval(map[string]interface{}{
+				"object": obj.Obj.Object,
+			})
+			switch {
+			case err != nil:
+				add(r.Name, r.Severity, fmt.Sprintf("evaluate expression failed: %v", err))
+			case out.Value() != true:
+				add(r.Name, r.Severity, r.Message)
+			}
+		}
+	}
+	return violations
+}
+
+// parseRule parses name or name=severity.
+func parseRule(spec string) (string, Severity, error) {
+	name, severity, found := strings.Cut(spec, "=")
+	if !found {
+		return name, SeverityError, nil
+	}
+	switch s := Severity(strings.ToLower(severity)); s {
+	case SeverityError, SeverityWarning:
+		return name, s, nil
+	}
+	return "", "", fmt.Errorf("invalid severity %q of policy rule %s, expected error or warning", severity, name)
+}
+
+// loadRules loads the CEL rules of the YAML file.
+func loadRules(env *cel.Env, file string) ([]*celRule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read policy file failed: %w", err)
+	}
+	var doc struct {
+		Rules []*celRule `json:"rules"`
+	}
+	if err := yaml.UnmarshalStrict(data, &doc); err != nil {
+		return nil, fmt.Errorf("decode policy file %s failed: %w", file, err)
+	}
+
+	for _, r := range doc.Rules {
+		if r.Name == "" || r.Expression == "" {
+			return nil, fmt.Errorf("policy file %s: rule name and expression are required", file)
+		}
+		if r.Severity == "" {
+			r.Severity = SeverityError
+		}
+		if r.Severity != SeverityError && r.Severity != SeverityWarning {
+			return nil, fmt.Errorf("policy file %s: invalid severity %q of rule %s, expected error or warning", file, r.Severity, r.Name)
+		}
+		if r.Message == "" {
+			r.Message = fmt.Sprintf("expression %q is false", r.Expression)
+		}
+
+		ast, issues := env.Compile(r.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("policy file %s: compile rule %s failed: %w", file, r.Name, issues.Err())
+		}
+		if t := ast.OutputType(); !t.IsExactType(cel.BoolType) && !t.IsExactType(cel.DynType) {
+			return nil, fmt.Errorf("policy file %s: rule %s must return a bool, got %s", file, r.Name, t)
+		}
+		r.program, err = env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("policy file %s: rule %s: %w", file, r.Name, err)
+		}
+	}
+	return doc.Rules, nil
+}
+
+func builtinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/appleboy/deploy-k8s/template"
+)
+
+func parseObjects(t *testing.T, file, data string) []*template.KubeObject {
+	t.Helper()
+	objs, err := template.ParseObject([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeObjs := make([]*template.KubeObject, 0, len(objs))
+	for i := range objs {
+		kubeObjs = append(kubeObjs, &template.KubeObject{
+			TplPath: file,
+			GVK:     objs[i].GroupVersionKind(),
+			Obj:     &objs[i],
+		})
+	}
+	return kubeObjs
+}
+
+const manifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    team: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: ghcr.io/acme/web:v1.2.3
+          resources:
+            limits:
+              cpu: 500m
+              memory: 128Mi
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: agent
+spec:
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox
+      containers:
+        - name: agent
+          image: docker.io/acme/agent:latest
+          securityContext:
+            privileged: true
+          resources:
+            limits:
+              cpu: 100m
+      volumes:
+        - name: logs
+          hostPath:
+            path: /var/log
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: backup
+  labels:
+    team: ops
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+            - name: backup
+              image: localhost:5000/backup@sha256:0123456789abcdef
+              resources:
+                limits:
+                  cpu: 1
+                  memory: 1Gi
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`
+
+func TestParseImage(t *testing.T) {
+	tests := []struct {
+		image, repo, tag, digest string
+	}{
+		{"nginx", "docker.io/library/nginx", "", ""},
+		{"nginx:1.25", "docker.io/library/nginx", "1.25", ""},
+		{"acme/web:v1", "docker.io/acme/web", "v1", ""},
+		{"ghcr.io/acme/web:v1", "ghcr.io/acme/web", "v1", ""},
+		{"localhost:5000/web", "localhost:5000/web", "", ""},
+		{"registry:5000/web:v1@sha256:abc", "registry:5000/web", "v1", "sha256:abc"},
+	}
+	for _, tt := range tests {
+		repo, tag, digest := parseImage(tt.image)
+		if repo != tt.repo || tag != tt.tag || digest != tt.digest {
+			t.Errorf("parseImage(%q) = %q, %q, %q, want %q, %q, %q", tt.image, repo, tag, digest, tt.repo, tt.tag, tt.digest)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	rules := `rules:
+  - name: team-label
+    severity: warning
+    match:
+      kinds: [Deployment, DaemonSet, CronJob]
+    expression: "has(object.metadata.labels) && 'team' in object.metadata.labels"
+    message: workloads must have a team label
+  - name: no-config-maps
+    match:
+      kinds: [ConfigMap]
+    expression: "object.data.size() > 0"
+`
+	if err := os.WriteFile(filepath.Join(dir, "rules.yaml"), []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := New(
+		WithRules("latest-tag", "privileged", "host-path", "allowed-registries", "resource-limits=warning"),
+		WithAllowedRegistries("ghcr.io/acme", "localhost:5000"),
+		WithFiles(filepath.Join(dir, "*.yaml")),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, v := range e.Check(parseObjects(t, "app.yaml", manifests)) {
+		got = append(got, string(v.Severity)+" "+v.Error())
+	}
+	want := []string{
+		"error app.yaml[1] DaemonSet/agent: allowed-registries: container init uses image busybox outside the allowed registries",
+		"error app.yaml[1] DaemonSet/agent: allowed-registries: container agent uses image docker.io/acme/agent:latest outside the allowed registries",
+		"error app.yaml[1] DaemonSet/agent: host-path: volume logs mounts the host path /var/log",
+		"error app.yaml[1] DaemonSet/agent: latest-tag: container init uses the latest tag of image busybox",
+		"error app.yaml[1] DaemonSet/agent: latest-tag: container agent uses the latest tag of image docker.io/acme/agent:latest",
+		"error app.yaml[1] DaemonSet/agent: privileged: container agent is privileged",
+		"warning app.yaml[1] DaemonSet/agent: resource-limits: container init has no cpu limit",
+		"warning app.yaml[1] DaemonSet/agent: resource-limits: container init has no memory limit",
+		"warning app.yaml[1] DaemonSet/agent: resource-limits: container agent has no memory limit",
+		"warning app.yaml[1] DaemonSet/agent: team-label: workloads must have a team label",
+		"error app.yaml[3] ConfigMap/config: no-config-maps: evaluate expression failed: no such key: data",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Check()\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"unknown rule", []Option{WithRules("no-root")}, `unknown policy rule "no-root"`},
+		{"invalid severity", []Option{WithRules("latest-tag=info")}, `invalid severity "info"`},
+		{"registries required", []Option{WithRules("allowed-registries")}, "allowed registries are required"},
+		{"no files", []Option{WithFiles(filepath.Join(dir, "missing*.yaml"))}, "matched no files"},
+		{
+			"compile error",
+			[]Option{WithFiles(write("compile.yaml", "rules:\n  - name: bad\n    expression: \"object.\"\n"))},
+			"compile rule bad failed",
+		},
+		{
+			"not a bool",
+			[]Option{WithFiles(write("string.yaml", "rules:\n  - name: str\n    expression: \"'yes'\"\n"))},
+			"rule str must return a bool",
+		},
+		{
+			"unknown field",
+			[]Option{WithFiles(write("field.yaml", "rules:\n  - name: x\n    expr: \"true\"\n"))},
+			"decode policy file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts...); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("New() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/appleboy/deploy-k8s/redact"
+	"github.com/appleboy/deploy-k8s/template"
+)
+
+// redacted returns a copy of the plugin with the credentials masked for the debug output.
+func (p *Plugin) redacted() *Plugin {
+	cfg := *p.Config
+	cfg.CaCert = redact.Value(cfg.CaCert)
+	cfg.ProxyURL = redactURL(cfg.ProxyURL)
+	cfg.Set = redactAssignments(cfg.Set)
+	cfg.SetEnv = redactAssignments(cfg.SetEnv)
+	auth := *p.AuthInfo
+	auth.Token = redact.Value(auth.Token)
+	auth.AgeKey = redact.Value(auth.AgeKey)
+	return &Plugin{
+		Config:   &cfg,
+		AuthInfo: &auth,
+	}
+}
+
+// redactURL masks the password of the URL.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return redact.Value(s)
+	}
+	return u.Redacted()
+}
+
+// redactAssignments masks the values of the KEY=VALUE assignments, like the --set values
+// or the container:KEY=VALUE of --set-env.
+func redactAssignments(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if key, value, ok := strings.Cut(v, "="); ok {
+			v = key + "=" + redact.Value(value)
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// sensitiveValues returns the values of the KEY=VALUE assignments whose key looks sensitive,
+// masking every value would hide unrelated output like true or info.
+func sensitiveValues(values []string) []string {
+	var result []string
+	for _, v := range values {
+		if key, value, ok := strings.Cut(v, "="); ok && redact.IsSensitive(key) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// canAddMasks reports whether the masks can be written, the workflow commands
+// would be mixed with the objects rendered to stdout.
+func (p *Plugin) canAddMasks() bool {
+	return p.Config.Action != "render" || p.Config.RenderOutput != ""
+}
+
+// addMasks masks the credentials and the sensitive settings in the GitHub Actions logs.
+func (p *Plugin) addMasks() {
+	if !p.canAddMasks() {
+		return
+	}
+	redact.AddMask(p.AuthInfo.Token, p.AuthInfo.AgeKey)
+	if u, err := url.Parse(p.Config.ProxyURL); err == nil && u.User != nil {
+		password, _ := u.User.Password()
+		redact.AddMask(password)
+	}
+	redact.AddMask(sensitiveValues(p.Config.Set)...)
+	redact.AddMask(sensitiveValues(p.Config.SetEnv)...)
+}
+
+// addObjectMasks masks the sensitive environment variables and
+// the data of the Secrets in the GitHub Actions logs.
+func (p *Plugin) addObjectMasks(envs map[string]any, kubeObjs []*template.KubeObject) {
+	if !p.canAddMasks() {
+		return
+	}
+	redact.AddMask(redact.EnvValues(envs)...)
+	for _, v := range kubeObjs {
+		redact.AddMask(redact.SecretValues(v.Obj.Object)...)
+	}
+}
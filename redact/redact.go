@@ -0,0 +1,156 @@
+package redact
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Mask replaces the sensitive values.
+const Mask = "******"
+
+// minMaskLength is the minimum length of the values masked on GitHub Actions,
+// masking shorter values would hide unrelated output.
+const minMaskLength = 4
+
+// output is where the GitHub Actions workflow commands are written.
+var output io.Writer = os.Stdout
+
+// sensitiveWords are the words of the names holding secrets, like DB_PASSWORD or api_key.
+var sensitiveWords = []string{
+	"token", "password", "passwd", "pass", "pwd", "secret", "key", "apikey",
+	"credential", "credentials", "auth", "cert", "private", "dsn",
+}
+
+// Value returns the mask if the value is not empty.
+func Value(v string) string {
+	if v == "" {
+		return ""
+	}
+	return Mask
+}
+
+// IsSensitive reports whether the name looks like it holds a secret.
+func IsSensitive(name string) bool {
+	parts := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r == '_' || r == '-' || r == '.'
+	})
+	for _, part := range parts {
+		for _, word := range sensitiveWords {
+			if part == word || (len(word) > 3 && strings.HasSuffix(part, word)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Env returns a copy of the environment map with the values of the sensitive names masked.
+func Env(envs map[string]any) map[string]any {
+	result := make(map[string]any, len(envs))
+	for k, v := range envs {
+		switch {
+		case IsSensitive(k):
+			result[k] = Mask
+		case isMap(v):
+			result[k] = Env(v.(map[string]any))
+		default:
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// EnvValues returns the string values of the sensitive names.
+func EnvValues(envs map[string]any) []string {
+	var values []string
+	for k, v := range envs {
+		switch {
+		case IsSensitive(k):
+			values = append(values, fmt.Sprint(v))
+		case isMap(v):
+			values = append(values, EnvValues(v.(map[string]any))...)
+		}
+	}
+	return values
+}
+
+// IsSecret reports whether the object is a core Secret.
+func IsSecret(obj map[string]interface{}) bool {
+	return obj["apiVersion"] == "v1" && obj["kind"] == "Secret"
+}
+
+// Object returns a copy of the object with the data and stringData of Secrets masked.
+func Object(obj map[string]interface{}) map[string]interface{} {
+	if !IsSecret(obj) {
+		return obj
+	}
+	return Data(obj)
+}
+
+// Data returns a copy of the object with the values of data and stringData masked, of any kind.
+func Data(obj map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(obj))
+	for k, v := range obj {
+		result[k] = v
+	}
+	for _, field := range []string{"data", "stringData"} {
+		data, ok := obj[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		masked := make(map[string]interface{}, len(data))
+		for k := range data {
+			masked[k] = Mask
+		}
+		result[field] = masked
+	}
+	return result
+}
+
+// SecretValues returns the plain values of the data and stringData of Secrets.
+func SecretValues(obj map[string]interface{}) []string {
+	if !IsSecret(obj) {
+		return nil
+	}
+	var values []string
+	if data, ok := obj["stringData"].(map[string]interface{}); ok {
+		for _, v := range data {
+			values = append(values, fmt.Sprint(v))
+		}
+	}
+	if data, ok := obj["data"].(map[string]interface{}); ok {
+		for _, v := range data {
+			s, _ := v.(string)
+			values = append(values, s)
+			if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+				values = append(values, string(decoded))
+			}
+		}
+	}
+	return values
+}
+
+// AddMask asks GitHub Actions to mask the values in the logs with ::add-mask::,
+// it does nothing outside of GitHub Actions. Multi-line values are masked line by line.
+func AddMask(values ...string) {
+	if os.Getenv("GITHUB_ACTIONS") != "true" {
+		return
+	}
+	for _, v := range values {
+		for _, line := range strings.Split(v, "\n") {
+			line = strings.TrimSpace(line)
+			if len(line) < minMaskLength {
+				continue
+			}
+			fmt.Fprintf(output, "::add-mask::%s\n", line)
+		}
+	}
+}
+
+func isMap(v any) bool {
+	_, ok := v.(map[string]any)
+	return ok
+}
@@ -0,0 +1,113 @@
+package redact
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIsSensitive(t *testing.T) {
+	sensitive := []string{"token", "PLUGIN_TOKEN", "db_password", "api-key", "apikey", "aws_secret_access_key", "ca_cert", "age_key", "github_token", "sops_age_key"}
+	for _, name := range sensitive {
+		if !IsSensitive(name) {
+			t.Errorf("IsSensitive(%q) = false, want true", name)
+		}
+	}
+	plain := []string{"namespace", "app_name", "drone_commit_author", "monkey", "image_tag", "replicas"}
+	for _, name := range plain {
+		if IsSensitive(name) {
+			t.Errorf("IsSensitive(%q) = true, want false", name)
+		}
+	}
+}
+
+func TestEnv(t *testing.T) {
+	envs := map[string]any{
+		"app_name":    "web",
+		"db_password": "p@ss",
+		"db": map[string]any{
+			"host":   "db.local",
+			"secret": "s3cr3t",
+		},
+	}
+	got := Env(envs)
+	want := map[string]any{
+		"app_name":    "web",
+		"db_password": Mask,
+		"db": map[string]any{
+			"host":   "db.local",
+			"secret": Mask,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Env() = %v, want %v", got, want)
+	}
+	if envs["db_password"] != "p@ss" {
+		t.Error("Env() modified the environment map")
+	}
+
+	values := EnvValues(envs)
+	sort.Strings(values)
+	if !reflect.DeepEqual(values, []string{"p@ss", "s3cr3t"}) {
+		t.Errorf("EnvValues() = %v", values)
+	}
+}
+
+func TestObject(t *testing.T) {
+	secret := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "db"},
+		"data":       map[string]interface{}{"password": "czNjcjN0"},
+		"stringData": map[string]interface{}{"user": "admin"},
+	}
+	got := Object(secret)
+	if got["data"].(map[string]interface{})["password"] != Mask || got["stringData"].(map[string]interface{})["user"] != Mask {
+		t.Errorf("Object() = %v, want masked data", got)
+	}
+	if secret["data"].(map[string]interface{})["password"] != "czNjcjN0" {
+		t.Error("Object() modified the secret")
+	}
+
+	values := SecretValues(secret)
+	sort.Strings(values)
+	if !reflect.DeepEqual(values, []string{"admin", "czNjcjN0", "s3cr3t"}) {
+		t.Errorf("SecretValues() = %v", values)
+	}
+
+	configMap := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"data":       map[string]interface{}{"key": "value"},
+	}
+	if !reflect.DeepEqual(Object(configMap), configMap) || SecretValues(configMap) != nil {
+		t.Error("ConfigMap should not be masked")
+	}
+	if got := Data(configMap); got["data"].(map[string]interface{})["key"] != Mask {
+		t.Errorf("Data() = %v, want masked data", got)
+	}
+}
+
+func TestAddMask(t *testing.T) {
+	var buf bytes.Buffer
+	old := output
+	output = &buf
+	defer func() { output = old }()
+
+	t.Setenv("GITHUB_ACTIONS", "")
+	AddMask("s3cr3t")
+	if buf.Len() != 0 {
+		t.Errorf("AddMask() outside of GitHub Actions = %q", buf.String())
+	}
+
+	t.Setenv("GITHUB_ACTIONS", "true")
+	AddMask("s3cr3t", "", "abc", "-----BEGIN KEY-----\nMIIEvQ\n-----END KEY-----")
+	want := "::add-mask::s3cr3t\n" +
+		"::add-mask::-----BEGIN KEY-----\n" +
+		"::add-mask::MIIEvQ\n" +
+		"::add-mask::-----END KEY-----\n"
+	if buf.String() != want {
+		t.Errorf("AddMask() = %q, want %q", buf.String(), want)
+	}
+}
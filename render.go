@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/appleboy/deploy-k8s/redact"
+	"github.com/appleboy/deploy-k8s/template"
+
+	"github.com/rs/zerolog/log"
+	"sigs.k8s.io/yaml"
+)
+
+// Render renders the templates and writes the objects to stdout or
+// a directory, without connecting to the cluster.
+func (p *Plugin) Render() error {
+	format := p.Config.RenderFormat
+	if format == "" {
+		format = "yaml"
+	}
+	if format != "yaml" && format != "json" {
+		return fmt.Errorf("unsupported render format: %s", format)
+	}
+
+	kubeObjs, err := p.Objects()
+	if err != nil {
+		return err
+	}
+	for _, v := range kubeObjs {
+		if v.Sensitive {
+			log.Warn().
+				Str("template", v.TplPath).
+				Str("kind", v.GVK.Kind).
+				Str("name", v.Obj.GetName()).
+				Msg("mask the data of the resource decrypted from sops")
+		}
+	}
+
+	if p.Config.RenderOutput == "" {
+		return writeObjects(os.Stdout, kubeObjs, format)
+	}
+
+	if err := os.MkdirAll(p.Config.RenderOutput, 0o755); err != nil {
+		return err
+	}
+	for i, v := range kubeObjs {
+		name := fmt.Sprintf(
+			"%03d-%s-%s.%s",
+			i, strings.ToLower(v.GVK.Kind), v.Obj.GetName(), format,
+		)
+		file := filepath.Join(p.Config.RenderOutput, name)
+
+		var buf bytes.Buffer
+		if err := writeObjects(&buf, []*template.KubeObject{v}, format); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+
+		log.Info().
+			Str("kind", v.GVK.Kind).
+			Str("name", v.Obj.GetName()).
+			Str("file", file).
+			Msg("render resource success")
+	}
+
+	return nil
+}
+
+// writeObjects writes the objects as a multi-document YAML stream
+// or a JSON List. The data and stringData of the objects decrypted from sops are masked.
+func writeObjects(w io.Writer, kubeObjs []*template.KubeObject, format string) error {
+	if format == "json" {
+		items := make([]interface{}, 0, len(kubeObjs))
+		for _, v := range kubeObjs {
+			items = append(items, renderObject(v))
+		}
+		data, err := json.MarshalIndent(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      items,
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	}
+
+	for _, v := range kubeObjs {
+		data, err := yaml.Marshal(renderObject(v))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n# Source: %s\n%s", v.TplPath, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renderObject returns the object to render, the plaintext of sops files never leaves the memory.
+func renderObject(v *template.KubeObject) map[string]interface{} {
+	if v.Sensitive {
+		return redact.Data(v.Obj.Object)
+	}
+	return v.Obj.Object
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/appleboy/deploy-k8s/config"
+)
+
+func TestRender(t *testing.T) {
+	output := t.TempDir()
+	p := &Plugin{
+		Config: &config.K8S{
+			Action:       "render",
+			Templates:    []string{"testdata/deployment01.yaml"},
+			RenderOutput: output,
+		},
+		AuthInfo: &config.AuthInfo{},
+	}
+
+	// server and token are not required
+	if err := p.Exec(); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(output, "000-deployment-nginx.yaml"))
+	if err != nil {
+		t.Fatalf("Expected rendered file: %s", err)
+	}
+	if !strings.Contains(string(data), "image: nginx:1.25.0") {
+		t.Errorf("Unexpected rendered object: %s", data)
+	}
+
+	p.Config.RenderFormat = "xml"
+	if err := p.Exec(); err == nil {
+		t.Errorf("Expected error for unsupported render format")
+	}
+}
+
+func TestWriteObjects(t *testing.T) {
+	objs := parseKubeObjects(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: configmap1
+---
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: drone-ci
+`)
+
+	var buf bytes.Buffer
+	if err := writeObjects(&buf, objs, "yaml"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if strings.Count(buf.String(), "---\n") != 2 {
+		t.Errorf("Expected 2 YAML documents, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeObjects(&buf, objs, "json"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	list := struct {
+		Kind  string        `json:"kind"`
+		Items []interface{} `json:"items"`
+	}{}
+	if err := json.Unmarshal(buf.Bytes(), &list); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if list.Kind != "List" || len(list.Items) != 2 {
+		t.Errorf("Expected List with 2 items, got: %s", buf.String())
+	}
+
+	// the data of the objects decrypted from sops is masked
+	objs[0].Obj.Object["data"] = map[string]interface{}{"password": "s3cr3t"}
+	objs[0].Sensitive = true
+	buf.Reset()
+	if err := writeObjects(&buf, objs, "yaml"); err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if strings.Contains(buf.String(), "s3cr3t") || !strings.Contains(buf.String(), "password: '******'") {
+		t.Errorf("Expected masked data, got: %s", buf.String())
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+// writeReport finishes the report with the result of the run, and writes the report
+// files and the GitHub Actions job summary. The error of the run is returned first.
+func (p *Plugin) writeReport(runErr error) error {
+	p.report.Finish(runErr)
+
+	errs := []error{runErr}
+	for _, file := range p.Config.Report {
+		if err := p.report.WriteFile(file); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		log.Info().Str("file", file).Msg("write report success")
+	}
+
+	if p.Config.ReportSummary {
+		if file := os.Getenv("GITHUB_STEP_SUMMARY"); file != "" {
+			if err := p.writeSummary(file); err != nil {
+				errs = append(errs, fmt.Errorf("write job summary failed: %w", err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeSummary appends the Markdown report to the job summary file.
+func (p *Plugin) writeSummary(file string) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := p.report.WriteMarkdown(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
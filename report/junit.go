@@ -0,0 +1,132 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     float64      `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      float64     `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+func (s *junitSuite) add(c junitCase) {
+	s.Tests++
+	s.Time = math.Round((s.Time+c.Time)*1000) / 1000
+	if c.Failure != nil {
+		s.Failures++
+	}
+	s.Cases = append(s.Cases, c)
+}
+
+func failure(msg string) *junitFailure {
+	if msg == "" {
+		return nil
+	}
+	return &junitFailure{Message: msg, Text: msg}
+}
+
+// WriteJUnit writes the report as JUnit XML, a test suite for the objects,
+// the images and the rollouts.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	timestamp := r.StartedAt.Format("2006-01-02T15:04:05")
+
+	objects := junitSuite{Name: "apply", Timestamp: timestamp}
+	for _, o := range r.Objects {
+		objects.add(junitCase{
+			Name:      o.String(),
+			ClassName: o.Template,
+			Time:      o.Duration,
+			Failure:   failure(o.Error),
+			SystemOut: string(o.Action),
+		})
+	}
+
+	images := junitSuite{Name: "images", Timestamp: timestamp}
+	for _, i := range r.Images {
+		images.add(junitCase{
+			Name:      i.Workload + "/" + i.Container,
+			ClassName: i.Namespace,
+			SystemOut: fmt.Sprintf("%s -> %s", i.Previous, i.Image),
+		})
+	}
+
+	rollouts := junitSuite{Name: "rollouts", Timestamp: timestamp}
+	for _, ro := range r.Rollouts {
+		rollouts.add(junitCase{
+			Name:      ro.Workload,
+			ClassName: ro.Namespace,
+			Time:      ro.Duration,
+			Failure:   failure(ro.Error),
+		})
+	}
+
+	suites := junitSuites{
+		Name: "deploy-k8s " + r.Action,
+		Time: r.Duration,
+	}
+	for _, s := range []junitSuite{objects, images, rollouts} {
+		if s.Tests == 0 {
+			continue
+		}
+		suites.Tests += s.Tests
+		suites.Failures += s.Failures
+		suites.Suites = append(suites.Suites, s)
+	}
+	// the run failed before or after the objects, like a template error
+	if r.Error != "" && suites.Failures == 0 {
+		suites.Tests++
+		suites.Failures++
+		suites.Suites = append(suites.Suites, junitSuite{
+			Name:      r.Action,
+			Tests:     1,
+			Failures:  1,
+			Time:      r.Duration,
+			Timestamp: timestamp,
+			Cases: []junitCase{{
+				Name:    r.Action,
+				Time:    r.Duration,
+				Failure: failure(r.Error),
+			}},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suites); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,61 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteMarkdown writes the report as Markdown tables, for the pull request
+// comments and the GitHub Actions job summary.
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	icon := ":white_check_mark:"
+	if r.Status == StatusFailed {
+		icon = ":x:"
+	}
+	fmt.Fprintf(b, "### %s deploy-k8s %s\n\n", icon, r.Action)
+	fmt.Fprintf(b, "Namespace `%s`, %s in %gs.\n", r.Namespace, r.Status, r.Duration)
+	if r.Error != "" {
+		fmt.Fprintf(b, "\n```\n%s\n```\n", r.Error)
+	}
+
+	if len(r.Objects) > 0 {
+		fmt.Fprint(b, "\n| Object | Template | Action | Status | Duration |\n| --- | --- | --- | --- | --- |\n")
+		for _, o := range r.Objects {
+			fmt.Fprintf(b, "| %s | %s | %s | %s | %gs |\n",
+				cell(o.String()), cell(o.Template), o.Action, status(o.Status, o.Error), o.Duration)
+		}
+	}
+
+	if len(r.Images) > 0 {
+		fmt.Fprint(b, "\n| Workload | Container | Previous | Image |\n| --- | --- | --- | --- |\n")
+		for _, i := range r.Images {
+			fmt.Fprintf(b, "| %s | %s | `%s` | `%s` |\n",
+				cell(i.Workload), cell(i.Container), cell(i.Previous), cell(i.Image))
+		}
+	}
+
+	if len(r.Rollouts) > 0 {
+		fmt.Fprint(b, "\n| Workload | Status | Duration |\n| --- | --- | --- |\n")
+		for _, ro := range r.Rollouts {
+			fmt.Fprintf(b, "| %s | %s | %gs |\n", cell(ro.Workload), status(ro.Status, ro.Error), ro.Duration)
+		}
+	}
+
+	return b.Flush()
+}
+
+func status(s Status, err string) string {
+	if err == "" {
+		return string(s)
+	}
+	return string(s) + ": " + cell(err)
+}
+
+// cell escapes the pipes and the new lines in the table cell.
+func cell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\r", "", "\n", "<br>").Replace(s)
+}
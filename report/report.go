@@ -0,0 +1,197 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Action is what the apply did to the object.
+type Action string
+
+const (
+	ActionCreated    Action = "created"
+	ActionConfigured Action = "configured"
+	ActionUnchanged  Action = "unchanged"
+)
+
+// Status of the run, the objects and the rollouts.
+type Status string
+
+const (
+	StatusSuccess Status = "success"
+	StatusFailed  Status = "failed"
+)
+
+// Object is an object applied to the cluster.
+type Object struct {
+	Template   string `json:"template,omitempty"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Action     Action `json:"action,omitempty"`
+	Status     Status `json:"status"`
+	// Duration in seconds.
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// String returns kind/namespace/name of the object.
+func (o *Object) String() string {
+	if o.Namespace == "" {
+		return o.Kind + "/" + o.Name
+	}
+	return o.Kind + "/" + o.Namespace + "/" + o.Name
+}
+
+// Image is a container image updated by the plugin.
+type Image struct {
+	Namespace string `json:"namespace"`
+	Workload  string `json:"workload"`
+	Container string `json:"container"`
+	Previous  string `json:"previous"`
+	Image     string `json:"image"`
+}
+
+// Rollout is the result of waiting for a workload rollout.
+type Rollout struct {
+	Namespace string `json:"namespace"`
+	Workload  string `json:"workload"`
+	Status    Status `json:"status"`
+	// Duration in seconds.
+	Duration float64 `json:"duration"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// Report of a deploy, the methods do nothing on a nil report.
+type Report struct {
+	Action    string     `json:"action"`
+	Namespace string     `json:"namespace"`
+	Status    Status     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	StartedAt time.Time  `json:"startedAt"`
+	Duration  float64    `json:"duration"`
+	Objects   []*Object  `json:"objects"`
+	Images    []*Image   `json:"images"`
+	Rollouts  []*Rollout `json:"rollouts"`
+}
+
+// New starts the report of the action.
+func New(action, namespace string) *Report {
+	return &Report{
+		Action:    action,
+		Namespace: namespace,
+		StartedAt: time.Now().UTC(),
+		Objects:   []*Object{},
+		Images:    []*Image{},
+		Rollouts:  []*Rollout{},
+	}
+}
+
+// AddObject records the object applied since start.
+func (r *Report) AddObject(o *Object, start time.Time, err error) {
+	if r == nil {
+		return
+	}
+	o.Duration = Seconds(time.Since(start))
+	o.Status, o.Error = result(err)
+	r.Objects = append(r.Objects, o)
+}
+
+// AddImage records the container image update.
+func (r *Report) AddImage(i *Image) {
+	if r == nil {
+		return
+	}
+	r.Images = append(r.Images, i)
+}
+
+// AddRollout records the rollout waited since start.
+func (r *Report) AddRollout(namespace, workload string, start time.Time, err error) {
+	if r == nil {
+		return
+	}
+	status, msg := result(err)
+	r.Rollouts = append(r.Rollouts, &Rollout{
+		Namespace: namespace,
+		Workload:  workload,
+		Status:    status,
+		Duration:  Seconds(time.Since(start)),
+		Error:     msg,
+	})
+}
+
+// Finish records the result of the run.
+func (r *Report) Finish(err error) {
+	if r == nil {
+		return
+	}
+	r.Duration = Seconds(time.Since(r.StartedAt))
+	r.Status, r.Error = result(err)
+}
+
+// WriteFile writes the report in the format of the file extension,
+// .json, .xml for JUnit or .md for Markdown.
+func (r *Report) WriteFile(file string) error {
+	write, err := r.writer(file)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(file); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write report %s failed: %w", file, err)
+	}
+	return f.Close()
+}
+
+// CheckFile returns an error if the format of the file extension is not supported.
+func CheckFile(file string) error {
+	_, err := (*Report)(nil).writer(file)
+	return err
+}
+
+func (r *Report) writer(file string) (func(io.Writer) error, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return r.WriteJSON, nil
+	case ".xml":
+		return r.WriteJUnit, nil
+	case ".md":
+		return r.WriteMarkdown, nil
+	}
+	return nil, fmt.Errorf("unsupported report file %s, expected .json, .xml or .md", file)
+}
+
+// WriteJSON writes the report as JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Seconds returns the duration in seconds, rounded to milliseconds.
+func Seconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
+
+func result(err error) (Status, string) {
+	if err != nil {
+		return StatusFailed, err.Error()
+	}
+	return StatusSuccess, ""
+}
@@ -0,0 +1,179 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newReport() *Report {
+	r := New("deploy", "default")
+	r.StartedAt = time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	r.Duration = 12.5
+	r.Status = StatusFailed
+	r.Error = "wait for deployment/web rollout failed: context deadline exceeded"
+	r.Objects = []*Object{
+		{
+			Template: "deploy/app.yaml", APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "config",
+			Action: ActionCreated, Status: StatusSuccess, Duration: 0.2,
+		},
+		{
+			Template: "deploy/app.yaml", APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "web",
+			Action: ActionConfigured, Status: StatusSuccess, Duration: 0.1,
+		},
+		{
+			Template: "deploy/ns.yaml", APIVersion: "v1", Kind: "Namespace", Name: "apps",
+			Action: ActionConfigured, Status: StatusFailed, Duration: 0.05, Error: "forbidden | denied",
+		},
+	}
+	r.Images = []*Image{
+		{Namespace: "default", Workload: "deployment/web", Container: "web", Previous: "nginx:1.24", Image: "nginx:1.25"},
+	}
+	r.Rollouts = []*Rollout{
+		{Namespace: "default", Workload: "deployment/web", Status: StatusFailed, Duration: 10, Error: "context deadline exceeded"},
+	}
+	return r
+}
+
+func TestAdd(t *testing.T) {
+	var nilReport *Report
+	nilReport.AddObject(&Object{}, time.Now(), nil)
+	nilReport.AddImage(&Image{})
+	nilReport.AddRollout("default", "deployment/web", time.Now(), nil)
+	nilReport.Finish(nil)
+
+	r := New("deploy", "default")
+	r.AddObject(&Object{Kind: "ConfigMap", Name: "config", Action: ActionCreated}, time.Now(), nil)
+	r.AddObject(&Object{Kind: "Deployment", Name: "web"}, time.Now(), errors.New("forbidden"))
+	r.AddRollout("default", "deployment/web", time.Now().Add(-1500*time.Millisecond), nil)
+	r.Finish(errors.New("forbidden"))
+
+	if r.Objects[0].Status != StatusSuccess || r.Objects[1].Status != StatusFailed || r.Objects[1].Error != "forbidden" {
+		t.Errorf("objects = %+v %+v", r.Objects[0], r.Objects[1])
+	}
+	if d := r.Rollouts[0].Duration; d < 1.5 || d > 2 {
+		t.Errorf("rollout duration = %g, want about 1.5", d)
+	}
+	if r.Status != StatusFailed || r.Error != "forbidden" {
+		t.Errorf("status = %s, error = %q", r.Status, r.Error)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newReport().WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Action   string
+		Status   string
+		Objects  []map[string]interface{}
+		Images   []map[string]interface{}
+		Rollouts []map[string]interface{}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Action != "deploy" || got.Status != "failed" || len(got.Objects) != 3 || len(got.Images) != 1 || len(got.Rollouts) != 1 {
+		t.Fatalf("WriteJSON() = %s", buf.String())
+	}
+	if got.Objects[0]["action"] != "created" || got.Objects[0]["template"] != "deploy/app.yaml" {
+		t.Errorf("object = %v", got.Objects[0])
+	}
+	if _, ok := got.Objects[2]["namespace"]; ok {
+		t.Errorf("cluster object has a namespace: %v", got.Objects[2])
+	}
+	if got.Images[0]["previous"] != "nginx:1.24" || got.Images[0]["image"] != "nginx:1.25" {
+		t.Errorf("image = %v", got.Images[0])
+	}
+
+	// the empty lists are written as arrays
+	buf.Reset()
+	if err := New("restart", "default").WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"objects": []`) {
+		t.Errorf("WriteJSON() = %s, want empty objects", buf.String())
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newReport().WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 5 || suites.Failures != 2 || len(suites.Suites) != 3 {
+		t.Fatalf("WriteJUnit() = %s", buf.String())
+	}
+	apply := suites.Suites[0]
+	if apply.Name != "apply" || apply.Tests != 3 || apply.Failures != 1 || apply.Time != 0.35 {
+		t.Errorf("apply suite = %+v", apply)
+	}
+	if c := apply.Cases[2]; c.Name != "Namespace/apps" || c.Failure == nil || c.Failure.Message != "forbidden | denied" {
+		t.Errorf("failed case = %+v", c)
+	}
+	if c := suites.Suites[1].Cases[0]; c.Name != "deployment/web/web" || c.SystemOut != "nginx:1.24 -> nginx:1.25" {
+		t.Errorf("image case = %+v", c)
+	}
+
+	// a run failed without objects has a failed test case
+	r := New("deploy", "default")
+	r.Finish(errors.New("template not found"))
+	buf.Reset()
+	if err := r.WriteJUnit(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `failures="1"`) || !strings.Contains(buf.String(), "template not found") {
+		t.Errorf("WriteJUnit() = %s", buf.String())
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newReport().WriteMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"### :x: deploy-k8s deploy\n",
+		"Namespace `default`, failed in 12.5s.\n",
+		"| ConfigMap/default/config | deploy/app.yaml | created | success | 0.2s |\n",
+		"| Namespace/apps | deploy/ns.yaml | configured | failed: forbidden \\| denied | 0.05s |\n",
+		"| deployment/web | web | `nginx:1.24` | `nginx:1.25` |\n",
+		"| deployment/web | failed: context deadline exceeded | 10s |\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("WriteMarkdown() missing %q\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	r := newReport()
+	for _, name := range []string{"report.json", "junit/report.xml", "summary.md"} {
+		file := filepath.Join(dir, name)
+		if err := r.WriteFile(file); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(file); err != nil || info.Size() == 0 {
+			t.Errorf("%s is not written: %v", name, err)
+		}
+	}
+
+	if err := CheckFile("report.txt"); err == nil {
+		t.Error("CheckFile(report.txt) error = nil, want unsupported report file")
+	}
+	if err := r.WriteFile(filepath.Join(dir, "report.yaml")); err == nil {
+		t.Error("WriteFile(report.yaml) error = nil, want unsupported report file")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/appleboy/deploy-k8s/config"
+	"github.com/appleboy/deploy-k8s/report"
+)
+
+func TestWriteReport(t *testing.T) {
+	dir := t.TempDir()
+	summary := filepath.Join(dir, "summary.md")
+	if err := os.WriteFile(summary, []byte("previous step\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_STEP_SUMMARY", summary)
+
+	p := &Plugin{
+		Config: &config.K8S{
+			Report:        []string{filepath.Join(dir, "report.json"), filepath.Join(dir, "report.xml")},
+			ReportSummary: true,
+		},
+		report: report.New("deploy", "default"),
+	}
+	runErr := errors.New("apply failed")
+	if err := p.writeReport(runErr); !errors.Is(err, runErr) {
+		t.Errorf("writeReport() error = %v, want %v", err, runErr)
+	}
+
+	for _, file := range p.Config.Report {
+		if _, err := os.Stat(file); err != nil {
+			t.Error(err)
+		}
+	}
+	content, err := os.ReadFile(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "previous step\n### :x: deploy-k8s deploy") {
+		t.Errorf("summary = %q, want the report appended", content)
+	}
+}
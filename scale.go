@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/retry"
+)
+
+// parseReplicas parses an absolute replica count like 3,
+// or a relative one like +2 or -1.
+func parseReplicas(v string) (int64, bool, error) {
+	relative := strings.HasPrefix(v, "+") || strings.HasPrefix(v, "-")
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid replicas %q, expected N, +N or -N", v)
+	}
+	if !relative && n < 0 {
+		return 0, false, fmt.Errorf("invalid replicas %q, expected N, +N or -N", v)
+	}
+	return n, relative, nil
+}
+
+// Scale kubernetes deployments and statefulsets through the scale subresource.
+func (p *Plugin) Scale(cfg *rest.Config) error {
+	if p.Config.Replicas == "" {
+		return nil
+	}
+	if p.Config.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if len(p.Config.Deployment) == 0 && p.Config.Selector == "" {
+		return fmt.Errorf("deployment or selector is required")
+	}
+
+	replicas, relative, err := parseReplicas(p.Config.Replicas)
+	if err != nil {
+		return err
+	}
+
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	workloads, err := p.workloads(ctx, dyn, "Deployment", "StatefulSet")
+	if err != nil {
+		return err
+	}
+
+	targets := make(map[string]int64, len(workloads))
+	for _, w := range workloads {
+		l := log.With().
+			Str("event", eventScale).
+			Str("namespace", p.Config.Namespace).
+			Str("workload", w.String()).
+			Logger()
+
+		var current, target int64
+		tryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			scale, err := dyn.Resource(w.Resource).
+				Namespace(p.Config.Namespace).
+				Get(ctx, w.Name, metav1.GetOptions{}, "scale")
+			if err != nil {
+				return err
+			}
+
+			current, _, err = unstructured.NestedInt64(scale.Object, "spec", "replicas")
+			if err != nil {
+				return err
+			}
+			target = replicas
+			if relative {
+				target = current + replicas
+			}
+			if target < 0 {
+				return fmt.Errorf("scale %s to %d replicas failed: replicas can't be negative", w, target)
+			}
+
+			if err := unstructured.SetNestedField(scale.Object, target, "spec", "replicas"); err != nil {
+				return err
+			}
+			_, err = dyn.Resource(w.Resource).
+				Namespace(p.Config.Namespace).
+				Update(ctx, scale, metav1.UpdateOptions{
+					FieldManager: "deploy-k8s-plugin",
+				}, "scale")
+			return err
+		})
+		if tryErr != nil {
+			return tryErr
+		}
+
+		targets[w.String()] = target
+		l.Info().
+			Int64("from", current).
+			Int64("to", target).
+			Msg("scale workload success")
+	}
+
+	if !p.Config.Wait {
+		return nil
+	}
+
+	for _, w := range workloads {
+		if err := p.waitForScale(ctx, dyn, w, targets[w.String()]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// waitForScale waits until the workload has the given number of ready replicas.
+func (p *Plugin) waitForScale(ctx context.Context, dyn dynamic.Interface, w workload, replicas int64) error {
+	l := log.With().
+		Str("event", eventRollout).
+		Str("namespace", p.Config.Namespace).
+		Str("workload", w.String()).
+		Logger()
+
+	start := time.Now()
+	var lastMsg string
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, p.Config.WaitTimeout, true, func(ctx context.Context) (bool, error) {
+		obj, err := dyn.Resource(w.Resource).
+			Namespace(p.Config.Namespace).
+			Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		done, msg := scaleStatus(obj, replicas)
+		if msg != lastMsg {
+			l.Info().Msg(msg)
+			lastMsg = msg
+		}
+		return done, nil
+	})
+	if err != nil {
+		err = &objectError{
+			Kind:      w.Kind,
+			Namespace: p.Config.Namespace,
+			Name:      w.Name,
+			Err:       fmt.Errorf("wait for %s scale failed: %w", w, err),
+		}
+	}
+	p.report.AddRollout(p.Config.Namespace, w.String(), start, err)
+	return err
+}
+
+// scaleStatus returns whether the workload reached the given number of ready replicas.
+func scaleStatus(obj *unstructured.Unstructured, replicas int64) (bool, string) {
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if obj.GetGeneration() > observed {
+		return false, "waiting for scale to be observed"
+	}
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	total, _, _ := unstructured.NestedInt64(obj.Object, "status", "replicas")
+	if ready < replicas || total != replicas {
+		return false, fmt.Sprintf("waiting for scale to finish: %d of %d replicas are ready", ready, replicas)
+	}
+	return true, "scale successfully finished"
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/appleboy/deploy-k8s/config"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestParseReplicas(t *testing.T) {
+	tests := []struct {
+		value    string
+		replicas int64
+		relative bool
+	}{
+		{"3", 3, false},
+		{"0", 0, false},
+		{"+2", 2, true},
+		{"-1", -1, true},
+	}
+
+	for _, tt := range tests {
+		replicas, relative, err := parseReplicas(tt.value)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", tt.value, err)
+			continue
+		}
+		if replicas != tt.replicas || relative != tt.relative {
+			t.Errorf("Expected %d (relative: %v) for %s, got: %d (relative: %v)", tt.replicas, tt.relative, tt.value, replicas, relative)
+		}
+	}
+
+	for _, v := range []string{"", "abc", "1.5"} {
+		if _, _, err := parseReplicas(v); err == nil {
+			t.Errorf("Expected error for %q", v)
+		}
+	}
+}
+
+func TestScaleStatus(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"generation": int64(3)},
+		"status": map[string]interface{}{
+			"observedGeneration": int64(3),
+			"readyReplicas":      int64(2),
+			"replicas":           int64(3),
+		},
+	}}
+
+	if done, _ := scaleStatus(obj, 3); done {
+		t.Errorf("Expected scale not finished")
+	}
+	if done, _ := scaleStatus(obj, 2); done {
+		t.Errorf("Expected scale not finished while replicas are terminating")
+	}
+
+	_ = unstructured.SetNestedField(obj.Object, int64(3), "status", "readyReplicas")
+	if done, msg := scaleStatus(obj, 3); !done {
+		t.Errorf("Expected scale finished, got: %s", msg)
+	}
+}
+
+func TestWorkloads(t *testing.T) {
+	newObj := func(kind, name string, labels map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "default",
+				"labels":    labels,
+			},
+		}}
+	}
+
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			{Group: "apps", Version: "v1", Resource: "deployments"}:  "DeploymentList",
+			{Group: "apps", Version: "v1", Resource: "statefulsets"}: "StatefulSetList",
+		},
+		newObj("Deployment", "web", map[string]interface{}{"app": "shop"}),
+		newObj("Deployment", "admin", map[string]interface{}{"app": "admin"}),
+		newObj("StatefulSet", "db", map[string]interface{}{"app": "shop"}),
+	)
+
+	p := &Plugin{
+		Config: &config.K8S{
+			Namespace:  "default",
+			Deployment: []string{"web", "deployment/admin"},
+			Selector:   "app=shop",
+		},
+	}
+
+	workloads, err := p.workloads(context.Background(), dyn, "Deployment", "StatefulSet")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := []string{"deployment/web", "deployment/admin", "statefulset/db"}
+	if len(workloads) != len(expected) {
+		t.Fatalf("Expected workloads: %v, got: %v", expected, workloads)
+	}
+	for i, w := range workloads {
+		if w.String() != expected[i] {
+			t.Errorf("Expected workload: %s, got: %s", expected[i], w)
+		}
+	}
+
+	p.Config.Deployment = []string{"daemonset/agent"}
+	if _, err := p.workloads(context.Background(), dyn, "Deployment", "StatefulSet"); err == nil {
+		t.Errorf("Expected error for unsupported workload kind")
+	}
+}
@@ -0,0 +1,258 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// envSource selects the environment variables with a prefix, or with the exact name.
+type envSource struct {
+	prefix string
+	// strip removes the prefix from the key, PLUGIN_APP_NAME is app_name
+	strip bool
+	// exact matches the whole name instead of a prefix
+	exact bool
+}
+
+// envProfiles are the environment variables set by the CI providers.
+// The sources of a profile are listed by precedence.
+var envProfiles = map[string][]envSource{
+	"drone": {
+		{prefix: "PLUGIN_", strip: true},
+		{prefix: "DRONE_"},
+	},
+	"github": {
+		{prefix: "INPUT_", strip: true},
+		{prefix: "GITHUB_"},
+		{prefix: "RUNNER_"},
+	},
+	"gitlab": {
+		{prefix: "CI_"},
+		{prefix: "GITLAB_"},
+	},
+	"woodpecker": {
+		{prefix: "PLUGIN_", strip: true},
+		{prefix: "CI_"},
+	},
+	"jenkins": {
+		{prefix: "BUILD_"},
+		{prefix: "JOB_"},
+		{prefix: "GIT_"},
+		{prefix: "CHANGE_"},
+		{prefix: "JENKINS_"},
+		{prefix: "BRANCH_NAME", exact: true},
+		{prefix: "NODE_NAME", exact: true},
+		{prefix: "WORKSPACE", exact: true},
+	},
+	"buildkite": {
+		{prefix: "BUILDKITE_"},
+	},
+}
+
+// DefaultEnvProfiles are the profiles used when none is set, the Drone and GitHub Actions variables.
+var DefaultEnvProfiles = []string{"drone", "github"}
+
+// EnvConfig selects the environment variables exposed as .envs in the templates.
+type EnvConfig struct {
+	// Profiles are the built-in CI provider profiles, see DefaultEnvProfiles.
+	Profiles []string
+	// Prefixes are stripped from the names, APP_NAME is name with the APP_ prefix.
+	Prefixes []string
+	// Allow are the names or glob patterns exposed as is, like HOME or DEPLOY_*.
+	Allow []string
+	// Typed parses the values of the stripped prefixes like PLUGIN_ which look like JSON,
+	// and turns the __ separated names into nested maps.
+	Typed bool
+}
+
+// LoadEnvironment returns the environment variables selected by the config, with lowercase keys.
+// When two variables map to the same key, the first source wins: the prefixes first,
+// then the allowed names and the profiles, in the order they are set.
+func LoadEnvironment(cfg EnvConfig) (map[string]any, error) {
+	return loadEnvironment(os.Environ(), cfg)
+}
+
+func loadEnvironment(environ []string, cfg EnvConfig) (map[string]any, error) {
+	profiles := cfg.Profiles
+	if len(profiles) == 0 {
+		profiles = DefaultEnvProfiles
+	}
+
+	sources := make([]envSource, 0, len(cfg.Prefixes)+len(cfg.Allow))
+	for _, prefix := range cfg.Prefixes {
+		sources = append(sources, envSource{prefix: prefix, strip: true})
+	}
+	for _, pattern := range cfg.Allow {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid env allow pattern %q: %w", pattern, err)
+		}
+		sources = append(sources, envSource{prefix: pattern, exact: true})
+	}
+	for _, name := range profiles {
+		profile, ok := envProfiles[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown env profile %q, expected %s", name, strings.Join(envProfileNames(), ", "))
+		}
+		sources = append(sources, profile...)
+	}
+
+	// sort the variables so the result never depends on the order of os.Environ
+	sorted := append([]string{}, environ...)
+	sort.Strings(sorted)
+
+	envs := make(map[string]any)
+	seen := make(map[string]bool)
+	for _, s := range sources {
+		for _, e := range sorted {
+			name, value, ok := strings.Cut(e, "=")
+			if !ok {
+				continue
+			}
+			key, ok := s.key(name)
+			if !ok {
+				continue
+			}
+
+			var v any = value
+			keys := []string{key}
+			if cfg.Typed && s.strip {
+				v = parseEnvValue(value)
+				keys = splitEnvKey(key)
+			}
+
+			// skip the same variable selected by another source
+			id := strings.Join(keys, ".")
+			if seen[name+"="+id] {
+				continue
+			}
+			seen[name+"="+id] = true
+
+			if !setEnv(envs, keys, v) {
+				log.Debug().
+					Str("env", name).
+					Str("key", id).
+					Msg("environment variable shadowed by a higher precedence source")
+			}
+		}
+	}
+	return envs, nil
+}
+
+// setEnv sets the value at the nested keys, it returns false if the key is already set.
+func setEnv(envs map[string]any, keys []string, value any) bool {
+	m := envs
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k]
+		if !ok {
+			child := map[string]any{}
+			m[k] = child
+			m = child
+			continue
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return false
+		}
+		m = child
+	}
+	last := keys[len(keys)-1]
+	if _, ok := m[last]; ok {
+		return false
+	}
+	m[last] = value
+	return true
+}
+
+// splitEnvKey splits db__host into the nested keys db and host.
+func splitEnvKey(key string) []string {
+	keys := strings.Split(key, "__")
+	for _, k := range keys {
+		if k == "" {
+			return []string{key}
+		}
+	}
+	return keys
+}
+
+// parseEnvValue returns the typed value if it looks like a JSON array, object,
+// number or bool, otherwise the string. Numbers are only parsed if they are written
+// in the canonical form, so 1.20 or 007 stay strings.
+func parseEnvValue(value string) any {
+	v := strings.TrimSpace(value)
+	switch {
+	case v == "true":
+		return true
+	case v == "false":
+		return false
+	case strings.HasPrefix(v, "{"), strings.HasPrefix(v, "["):
+		decoder := json.NewDecoder(strings.NewReader(v))
+		decoder.UseNumber()
+		var data any
+		if err := decoder.Decode(&data); err != nil || decoder.More() {
+			return value
+		}
+		return convertJSONNumbers(data)
+	}
+
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil && strconv.FormatInt(n, 10) == v {
+		return n
+	}
+	if f, err := strconv.ParseFloat(v, 64); err == nil && strconv.FormatFloat(f, 'f', -1, 64) == v {
+		return f
+	}
+	return value
+}
+
+// convertJSONNumbers converts the json.Number values to int64 or float64.
+func convertJSONNumbers(data any) any {
+	switch v := data.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, item := range v {
+			v[k] = convertJSONNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = convertJSONNumbers(item)
+		}
+	}
+	return data
+}
+
+// key returns the template key of the environment variable if it is selected by the source.
+func (s envSource) key(name string) (string, bool) {
+	if s.exact {
+		if ok, _ := path.Match(s.prefix, name); !ok {
+			return "", false
+		}
+		return strings.ToLower(name), true
+	}
+	if !strings.HasPrefix(name, s.prefix) || len(name) == len(s.prefix) {
+		return "", false
+	}
+	if s.strip {
+		name = strings.TrimPrefix(name, s.prefix)
+	}
+	return strings.ToLower(name), true
+}
+
+func envProfileNames() []string {
+	names := make([]string, 0, len(envProfiles))
+	for name := range envProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
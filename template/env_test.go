@@ -0,0 +1,179 @@
+package template
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadEnvironment(t *testing.T) {
+	environ := []string{
+		"PLUGIN_APP_NAME=plugin",
+		"DRONE_COMMIT_SHA=abc",
+		"INPUT_APP_NAME=input",
+		"GITHUB_SHA=def",
+		"CI_COMMIT_SHA=123",
+		"CI_PROJECT_NAME=app",
+		"GITLAB_USER_LOGIN=bob",
+		"BUILDKITE_BRANCH=main",
+		"BUILD_NUMBER=42",
+		"BRANCH_NAME=main",
+		"APP_NAME=prefix",
+		"APP_REPLICAS=3",
+		"DEPLOY_ENV=production",
+		"HOME=/root",
+		"PLUGIN_=empty",
+	}
+
+	tests := []struct {
+		name    string
+		cfg     EnvConfig
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name: "default profiles",
+			cfg:  EnvConfig{},
+			want: map[string]any{
+				"app_name":         "plugin",
+				"drone_commit_sha": "abc",
+				"github_sha":       "def",
+			},
+		},
+		{
+			name: "profile order decides the precedence",
+			cfg:  EnvConfig{Profiles: []string{"github", "drone"}},
+			want: map[string]any{
+				"app_name":         "input",
+				"drone_commit_sha": "abc",
+				"github_sha":       "def",
+			},
+		},
+		{
+			name: "gitlab",
+			cfg:  EnvConfig{Profiles: []string{"gitlab"}},
+			want: map[string]any{
+				"ci_commit_sha":     "123",
+				"ci_project_name":   "app",
+				"gitlab_user_login": "bob",
+			},
+		},
+		{
+			name: "jenkins and buildkite",
+			cfg:  EnvConfig{Profiles: []string{"Jenkins", "buildkite"}},
+			want: map[string]any{
+				"build_number":     "42",
+				"branch_name":      "main",
+				"buildkite_branch": "main",
+			},
+		},
+		{
+			name: "prefix and allow take precedence over profiles",
+			cfg: EnvConfig{
+				Profiles: []string{"drone"},
+				Prefixes: []string{"APP_"},
+				Allow:    []string{"DEPLOY_*", "HOME"},
+			},
+			want: map[string]any{
+				"name":             "prefix",
+				"replicas":         "3",
+				"deploy_env":       "production",
+				"home":             "/root",
+				"app_name":         "plugin",
+				"drone_commit_sha": "abc",
+			},
+		},
+		{
+			name:    "unknown profile",
+			cfg:     EnvConfig{Profiles: []string{"travis"}},
+			wantErr: `unknown env profile "travis"`,
+		},
+		{
+			name:    "invalid allow pattern",
+			cfg:     EnvConfig{Allow: []string{"["}},
+			wantErr: "invalid env allow pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadEnvironment(environ, tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadEnvironment() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadEnvironment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadEnvironmentOrder(t *testing.T) {
+	// the result never depends on the order of the environment
+	a, err := loadEnvironment([]string{"DRONE_X=drone", "PLUGIN_DRONE_X=plugin"}, EnvConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := loadEnvironment([]string{"PLUGIN_DRONE_X=plugin", "DRONE_X=drone"}, EnvConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a["drone_x"] != "plugin" || b["drone_x"] != "plugin" {
+		t.Errorf("drone_x = %v and %v, want plugin", a["drone_x"], b["drone_x"])
+	}
+}
+
+func TestLoadEnvironmentTyped(t *testing.T) {
+	environ := []string{
+		"PLUGIN_ENABLE_HPA=true",
+		"PLUGIN_REPLICAS=3",
+		"PLUGIN_RATIO=0.5",
+		"PLUGIN_GO_VERSION=1.20",
+		"PLUGIN_ZONE=007",
+		"PLUGIN_HOSTS=[\"a.example.com\",\"b.example.com\"]",
+		"PLUGIN_LIMITS={\"cpu\":\"500m\",\"replicas\":2}",
+		"PLUGIN_BROKEN={\"cpu\"",
+		"PLUGIN_DB__HOST=db.local",
+		"PLUGIN_DB__PORT=5432",
+		"PLUGIN_CACHE=redis",
+		"PLUGIN_CACHE__HOST=cache.local",
+		"DRONE_BUILD_NUMBER=42",
+	}
+
+	got, err := loadEnvironment(environ, EnvConfig{Profiles: []string{"drone"}, Typed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"enable_hpa": true,
+		"replicas":   int64(3),
+		"ratio":      0.5,
+		"go_version": "1.20",
+		"zone":       "007",
+		"hosts":      []any{"a.example.com", "b.example.com"},
+		"limits":     map[string]any{"cpu": "500m", "replicas": int64(2)},
+		"broken":     `{"cpu"`,
+		"db":         map[string]any{"host": "db.local", "port": int64(5432)},
+		// the value wins over the nested key
+		"cache": "redis",
+		// only the stripped prefixes are typed
+		"drone_build_number": "42",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loadEnvironment() = %#v, want %#v", got, want)
+	}
+
+	got, err = loadEnvironment(environ, EnvConfig{Profiles: []string{"drone"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["replicas"] != "3" || got["db__host"] != "db.local" {
+		t.Errorf("untyped values = %v and %v, want strings", got["replicas"], got["db__host"])
+	}
+}
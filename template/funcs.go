@@ -0,0 +1,52 @@
+package template
+
+import (
+	"errors"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// funcMap returns the Sprig function library plus the project helpers.
+// The environment is only exposed through .envs, the same as Helm removes
+// env and expandenv, so the templates can't read the plugin credentials.
+func funcMap() template.FuncMap {
+	f := sprig.TxtFuncMap()
+	delete(f, "env")
+	delete(f, "expandenv")
+	f["toYaml"] = toYaml
+	f["fromYaml"] = fromYaml
+	f["required"] = required
+	return f
+}
+
+// toYaml converts the value to a YAML document without the trailing newline.
+func toYaml(v interface{}) (string, error) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// fromYaml converts a YAML document to a map.
+func fromYaml(str string) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(str), &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// required returns an error with the message if the value is empty.
+func required(msg string, v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, errors.New(msg)
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
@@ -0,0 +1,128 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// ChartRelease is a local Helm chart rendered to unstructured objects.
+type ChartRelease struct {
+	Name      string
+	Namespace string
+	Chart     *chart.Chart
+	Values    map[string]any
+	Manifest  string
+	Hooks     []*release.Hook
+	// CRDs of the crds directories, applied before the objects and not part of the release.
+	CRDs    []*KubeObject
+	Objects []*KubeObject
+}
+
+// ParseChart renders the local Helm chart directory or archive with the values,
+// without connecting to the cluster or fetching from a chart repository.
+// The CRDs of the crds directories are not templated, hooks are not rendered into the objects.
+func ParseChart(path, name, namespace string, values map[string]any) (*ChartRelease, error) {
+	chrt, err := loader.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("load chart %s failed: %w", path, err)
+	}
+	if name == "" {
+		name = chrt.Name()
+	}
+	if values == nil {
+		values = map[string]any{}
+	}
+
+	if err := chartutil.ProcessDependencies(chrt, values); err != nil {
+		return nil, fmt.Errorf("process chart dependencies failed: %w", err)
+	}
+
+	caps := chartutil.DefaultCapabilities
+	renderValues, err := chartutil.ToRenderValues(chrt, values, chartutil.ReleaseOptions{
+		Name:      name,
+		Namespace: namespace,
+		Revision:  1,
+		IsInstall: true,
+	}, caps)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("render chart %s failed: %w", path, err)
+	}
+	for file := range files {
+		// NOTES.txt is shown to users, not applied
+		if strings.HasSuffix(file, "NOTES.txt") {
+			delete(files, file)
+		}
+	}
+
+	hooks, manifests, err := releaseutil.SortManifests(files, caps.APIVersions, releaseutil.InstallOrder)
+	if err != nil {
+		return nil, fmt.Errorf("sort chart manifests failed: %w", err)
+	}
+
+	result := &ChartRelease{
+		Name:      name,
+		Namespace: namespace,
+		Chart:     chrt,
+		Values:    values,
+		Hooks:     hooks,
+		CRDs:      []*KubeObject{},
+		Objects:   []*KubeObject{},
+	}
+
+	// the CRDs are installed before the resources using them, like helm install
+	for _, crd := range chrt.CRDObjects() {
+		objs, err := ParseObject(crd.File.Data)
+		if err != nil {
+			return nil, fmt.Errorf("parse chart crd %s failed: %w", crd.Filename, err)
+		}
+		kubeObjs, err := newKubeObjects(crd.Filename, objs)
+		if err != nil {
+			return nil, err
+		}
+		result.CRDs = append(result.CRDs, kubeObjs...)
+	}
+
+	for _, h := range hooks {
+		events := make([]string, 0, len(h.Events))
+		for _, e := range h.Events {
+			events = append(events, string(e))
+		}
+		log.Warn().
+			Str("template", h.Path).
+			Str("kind", h.Kind).
+			Str("name", h.Name).
+			Strs("hooks", events).
+			Msg("helm hook is not run")
+	}
+
+	var manifest strings.Builder
+	for _, m := range manifests {
+		fmt.Fprintf(&manifest, "---\n# Source: %s\n%s\n", m.Name, m.Content)
+
+		objs, err := ParseObject([]byte(m.Content))
+		if err != nil {
+			return nil, fmt.Errorf("parse chart manifest %s failed: %w", m.Name, err)
+		}
+		kubeObjs, err := newKubeObjects(m.Name, objs)
+		if err != nil {
+			return nil, err
+		}
+		result.Objects = append(result.Objects, kubeObjs...)
+	}
+	result.Manifest = manifest.String()
+
+	return result, nil
+}
@@ -0,0 +1,128 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseChart(t *testing.T) {
+	chartDir := filepath.Join(t.TempDir(), "app")
+	for _, dir := range []string{"templates", "crds"} {
+		if err := os.MkdirAll(filepath.Join(chartDir, dir), 0o755); err != nil {
+			t.Fatalf("Failed to create chart dir: %v", err)
+		}
+	}
+
+	files := map[string]string{
+		"Chart.yaml": `
+apiVersion: v2
+name: app
+version: 0.1.0
+`,
+		"values.yaml": `
+replicas: 1
+image: nginx:1.25.0
+`,
+		"templates/NOTES.txt": `Thank you for installing {{ .Chart.Name }}.`,
+		"templates/deployment.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+spec:
+  replicas: {{ .Values.replicas }}
+  template:
+    spec:
+      containers:
+      - name: app
+        image: {{ .Values.image }}
+`,
+		"templates/configmap.yaml": `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-config
+data:
+  key: value
+`,
+		"crds/crontab.yaml": `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: crontabs.stable.example.com
+spec:
+  group: stable.example.com
+  names:
+    kind: CronTab
+    plural: crontabs
+  scope: Namespaced
+`,
+		"templates/hook.yaml": `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: {{ .Release.Name }}-migrate
+  annotations:
+    helm.sh/hook: pre-install
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(chartDir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	rel, err := ParseChart(chartDir, "web", "production", map[string]any{"replicas": int64(3)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if rel.Name != "web" || rel.Namespace != "production" {
+		t.Errorf("Unexpected release: %s/%s", rel.Namespace, rel.Name)
+	}
+	if len(rel.Hooks) != 1 {
+		t.Errorf("Expected 1 hook, got: %d", len(rel.Hooks))
+	}
+	if len(rel.CRDs) != 1 || rel.CRDs[0].GVK.Kind != "CustomResourceDefinition" || rel.CRDs[0].TplPath != "app/crds/crontab.yaml" {
+		t.Errorf("Expected the CRD of crds/crontab.yaml, got: %v", rel.CRDs)
+	}
+	if strings.Contains(rel.Manifest, "CustomResourceDefinition") {
+		t.Errorf("Expected manifest without CRDs, got: %s", rel.Manifest)
+	}
+	if len(rel.Objects) != 2 {
+		t.Fatalf("Expected 2 objects, got: %d", len(rel.Objects))
+	}
+
+	// install order: ConfigMap before Deployment
+	if rel.Objects[0].GVK.Kind != "ConfigMap" || rel.Objects[0].Obj.GetName() != "web-config" {
+		t.Errorf("Unexpected first object: %s %s", rel.Objects[0].GVK.Kind, rel.Objects[0].Obj.GetName())
+	}
+	deployment := rel.Objects[1]
+	if deployment.GVK.Kind != "Deployment" || deployment.Obj.GetNamespace() != "production" {
+		t.Errorf("Unexpected second object: %s %s", deployment.GVK.Kind, deployment.Obj.GetNamespace())
+	}
+	if deployment.TplPath != "app/templates/deployment.yaml" {
+		t.Errorf("Expected TplPath: app/templates/deployment.yaml, got: %s", deployment.TplPath)
+	}
+	if !strings.Contains(deployment.TplData, `"replicas":3`) || !strings.Contains(deployment.TplData, "nginx:1.25.0") {
+		t.Errorf("Expected merged values in deployment, got: %s", deployment.TplData)
+	}
+	if !strings.Contains(rel.Manifest, "# Source: app/templates/deployment.yaml") {
+		t.Errorf("Expected manifest with sources, got: %s", rel.Manifest)
+	}
+
+	rel, err = ParseChart(chartDir, "", "default", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if rel.Name != "app" {
+		t.Errorf("Expected default release name: app, got: %s", rel.Name)
+	}
+
+	if _, err := ParseChart(filepath.Join(chartDir, "missing"), "", "default", nil); err == nil {
+		t.Errorf("Expected error for missing chart")
+	}
+}
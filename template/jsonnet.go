@@ -0,0 +1,91 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/google/go-jsonnet"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+)
+
+// isJsonnetFile reports whether the file is evaluated by jsonnet.
+func isJsonnetFile(file string) bool {
+	return filepath.Ext(file) == ".jsonnet"
+}
+
+// isJsonnetLibrary reports whether the file is a jsonnet library,
+// which is only imported by other files and renders no resources.
+func isJsonnetLibrary(file string) bool {
+	return filepath.Ext(file) == ".libsonnet"
+}
+
+// ParseJsonnet evaluates the jsonnet file and returns a list of unstructured objects.
+// The environment map and values are passed as the envs and values external variables,
+// for example std.extVar('envs').app_namespace.
+func ParseJsonnet(file string, envMap, values map[string]any) ([]unstructured.Unstructured, error) {
+	envs, err := json.Marshal(envMap)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = map[string]any{}
+	}
+	vals, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	vm := jsonnet.MakeVM()
+	vm.ExtCode("envs", string(envs))
+	vm.ExtCode("values", string(vals))
+	vm.Importer(&jsonnet.FileImporter{
+		JPaths: []string{filepath.Dir(file)},
+	})
+
+	output, err := vm.EvaluateFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("evaluate jsonnet %s failed: %w", file, err)
+	}
+
+	// the integers are int64 like the objects decoded from YAML, not float64
+	var data interface{}
+	if err := utiljson.Unmarshal([]byte(output), &data); err != nil {
+		return nil, fmt.Errorf("decode jsonnet %s output failed: %w", file, err)
+	}
+
+	return jsonnetObjects(data)
+}
+
+// jsonnetObjects converts an object, an array or a List to unstructured objects.
+func jsonnetObjects(data interface{}) ([]unstructured.Unstructured, error) {
+	var result []unstructured.Unstructured
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			objs, err := jsonnetObjects(item)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, objs...)
+		}
+	case map[string]interface{}:
+		obj := unstructured.Unstructured{Object: v}
+		if obj.IsList() {
+			items, _, err := unstructured.NestedSlice(v, "items")
+			if err != nil {
+				return nil, err
+			}
+			return jsonnetObjects(items)
+		}
+		if obj.GetKind() == "" || obj.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("decode jsonnet object failed: apiVersion and kind are required")
+		}
+		result = append(result, obj)
+	case nil:
+	default:
+		return nil, fmt.Errorf("decode jsonnet output failed: unexpected %T, expected object, array or List", data)
+	}
+	return result, nil
+}
@@ -0,0 +1,103 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSetJsonnet(t *testing.T) {
+	tempDir := t.TempDir()
+
+	files := map[string]string{
+		"lib.libsonnet": `
+{
+  configMap(name, namespace):: {
+    apiVersion: 'v1',
+    kind: 'ConfigMap',
+    metadata: { name: name, namespace: namespace },
+  },
+}
+`,
+		"object.jsonnet": `
+local lib = import 'lib.libsonnet';
+lib.configMap('object', std.extVar('envs').app_namespace)
+`,
+		"array.jsonnet": `
+[
+  { apiVersion: 'v1', kind: 'ServiceAccount', metadata: { name: 'sa-' + i } }
+  for i in ['a', 'b']
+]
+`,
+		"list.jsonnet": `
+{
+  apiVersion: 'v1',
+  kind: 'List',
+  items: [
+    {
+      apiVersion: 'apps/v1',
+      kind: 'Deployment',
+      metadata: { name: 'app' },
+      spec: { replicas: std.extVar('values').replicas },
+    },
+  ],
+}
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(tempDir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	objects, err := ParseSet(
+		[]string{
+			filepath.Join(tempDir, "*.libsonnet"),
+			filepath.Join(tempDir, "object.jsonnet"),
+			filepath.Join(tempDir, "array.jsonnet"),
+			filepath.Join(tempDir, "list.jsonnet"),
+		},
+		map[string]any{"app_namespace": "testing"},
+		WithValues(map[string]any{"replicas": 3}),
+	)
+	if err != nil {
+		t.Fatalf("Error parsing objects: %v", err)
+	}
+
+	if len(objects) != 4 {
+		t.Fatalf("Expected 4 objects, got: %d", len(objects))
+	}
+
+	expected := []struct {
+		kind string
+		name string
+	}{
+		{"ConfigMap", "object"},
+		{"ServiceAccount", "sa-a"},
+		{"ServiceAccount", "sa-b"},
+		{"Deployment", "app"},
+	}
+	for i, e := range expected {
+		if objects[i].GVK.Kind != e.kind || objects[i].Obj.GetName() != e.name {
+			t.Errorf("Expected %s/%s, got: %s/%s", e.kind, e.name, objects[i].GVK.Kind, objects[i].Obj.GetName())
+		}
+	}
+	if objects[0].Obj.GetNamespace() != "testing" {
+		t.Errorf("Expected namespace: testing, got: %s", objects[0].Obj.GetNamespace())
+	}
+	if objects[3].TplPath != filepath.Join(tempDir, "list.jsonnet") {
+		t.Errorf("Unexpected TplPath: %s", objects[3].TplPath)
+	}
+	// the numbers are decoded like YAML, the integers are int64
+	if replicas := objects[3].Obj.Object["spec"].(map[string]interface{})["replicas"]; replicas != int64(3) {
+		t.Errorf("Expected replicas: int64 3, got: %T %v", replicas, replicas)
+	}
+
+	bad := filepath.Join(tempDir, "bad.jsonnet")
+	if err := os.WriteFile(bad, []byte(`"string"`), 0o644); err != nil {
+		t.Fatalf("Failed to write bad.jsonnet: %v", err)
+	}
+	if _, err := ParseSet([]string{bad}, map[string]any{}); err == nil {
+		t.Errorf("Expected error for jsonnet output which is not an object")
+	}
+}
@@ -0,0 +1,30 @@
+package template
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// ParseKustomization builds the kustomize overlay in the directory
+// and returns a list of unstructured objects.
+func ParseKustomization(dir string) ([]*KubeObject, error) {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resMap, err := k.Run(filesys.MakeFsOnDisk(), dir)
+	if err != nil {
+		return nil, fmt.Errorf("build kustomization %s failed: %w", dir, err)
+	}
+
+	data, err := resMap.AsYaml()
+	if err != nil {
+		return nil, err
+	}
+
+	objs, err := ParseObject(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return newKubeObjects(dir, objs)
+}
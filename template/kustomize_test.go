@@ -0,0 +1,64 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseKustomization(t *testing.T) {
+	tempDir := t.TempDir()
+	base := filepath.Join(tempDir, "base")
+	overlay := filepath.Join(tempDir, "overlays", "production")
+	for _, dir := range []string{base, overlay} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+
+	files := map[string]string{
+		filepath.Join(base, "kustomization.yaml"): `
+resources:
+- configmap.yaml
+`,
+		filepath.Join(base, "configmap.yaml"): `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+data:
+  key: value
+`,
+		filepath.Join(overlay, "kustomization.yaml"): `
+namespace: production
+namePrefix: prod-
+resources:
+- ../../base
+`,
+	}
+	for file, content := range files {
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", file, err)
+		}
+	}
+
+	objects, err := ParseKustomization(overlay)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if len(objects) != 1 {
+		t.Fatalf("Expected 1 object, got: %d", len(objects))
+	}
+	obj := objects[0]
+	if obj.TplPath != overlay {
+		t.Errorf("Expected TplPath: %s, got: %s", overlay, obj.TplPath)
+	}
+	if obj.GVK.Kind != "ConfigMap" || obj.Obj.GetName() != "prod-app" || obj.Obj.GetNamespace() != "production" {
+		t.Errorf("Unexpected object: %s %s/%s", obj.GVK.Kind, obj.Obj.GetNamespace(), obj.Obj.GetName())
+	}
+
+	if _, err := ParseKustomization(filepath.Join(tempDir, "missing")); err == nil {
+		t.Errorf("Expected error for missing kustomization")
+	}
+}
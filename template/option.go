@@ -0,0 +1,123 @@
+package template
+
+import (
+	"io"
+	"os"
+)
+
+// Option configures how the templates are rendered.
+type Option func(*options)
+
+type options struct {
+	name           string
+	values         map[string]any
+	strict         bool
+	helpers        []helper
+	helperPatterns []string
+	ageKey         string
+	allowMissing   bool
+	include        []string
+	exclude        []string
+	stdin          io.Reader
+}
+
+// helper is a file with shared templates used by {{ include }} or {{ template }}.
+type helper struct {
+	name    string
+	content string
+}
+
+// templateNames returns the name of the template and its helpers.
+func (o *options) templateNames() []string {
+	names := []string{o.name}
+	for _, h := range o.helpers {
+		names = append(names, h.name)
+	}
+	return names
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{
+		name:   "message",
+		values: map[string]any{},
+		stdin:  os.Stdin,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithValues exposes the values as .Values in the templates.
+func WithValues(values map[string]any) Option {
+	return func(o *options) {
+		if values != nil {
+			o.values = values
+		}
+	}
+}
+
+// WithStrict returns an error when a template refers to a variable which is not defined.
+func WithStrict(strict bool) Option {
+	return func(o *options) {
+		o.strict = strict
+	}
+}
+
+// withName sets the template name reported in errors.
+func withName(name string) Option {
+	return func(o *options) {
+		o.name = name
+	}
+}
+
+// WithHelpers loads the helper files matching the glob patterns into every template,
+// so the templates they define can be used across files. Used by ParseSet.
+func WithHelpers(patterns ...string) Option {
+	return func(o *options) {
+		o.helperPatterns = append(o.helperPatterns, patterns...)
+	}
+}
+
+// withHelpers adds the helper templates.
+func withHelpers(helpers []helper) Option {
+	return func(o *options) {
+		o.helpers = append(o.helpers, helpers...)
+	}
+}
+
+// WithAgeKey sets the age identities used to decrypt sops files.
+func WithAgeKey(key string) Option {
+	return func(o *options) {
+		o.ageKey = key
+	}
+}
+
+// WithAllowMissing skips the template patterns matching no files instead of returning an error.
+func WithAllowMissing(allow bool) Option {
+	return func(o *options) {
+		o.allowMissing = allow
+	}
+}
+
+// WithInclude sets the patterns of the files loaded from template directories,
+// matched against the path relative to the directory or the base name.
+func WithInclude(patterns ...string) Option {
+	return func(o *options) {
+		o.include = append(o.include, patterns...)
+	}
+}
+
+// WithExclude sets the patterns of the files and directories skipped in template directories.
+func WithExclude(patterns ...string) Option {
+	return func(o *options) {
+		o.exclude = append(o.exclude, patterns...)
+	}
+}
+
+// withStdin sets the reader of the - template.
+func withStdin(r io.Reader) Option {
+	return func(o *options) {
+		o.stdin = r
+	}
+}
@@ -0,0 +1,382 @@
+package template
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// sopsMetadata is the sops section of an encrypted file.
+type sopsMetadata struct {
+	KeyGroups []struct {
+		Age []sopsAgeKey `yaml:"age"`
+	} `yaml:"key_groups"`
+	Age               []sopsAgeKey `yaml:"age"`
+	LastModified      string       `yaml:"lastmodified"`
+	MAC               string       `yaml:"mac"`
+	UnencryptedSuffix string       `yaml:"unencrypted_suffix"`
+	EncryptedSuffix   string       `yaml:"encrypted_suffix"`
+	UnencryptedRegex  string       `yaml:"unencrypted_regex"`
+	EncryptedRegex    string       `yaml:"encrypted_regex"`
+	// MACOnlyEncrypted is true if the mac only covers the encrypted values.
+	MACOnlyEncrypted        bool   `yaml:"mac_only_encrypted"`
+	UnencryptedCommentRegex string `yaml:"unencrypted_comment_regex"`
+	EncryptedCommentRegex   string `yaml:"encrypted_comment_regex"`
+}
+
+type sopsAgeKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+var reSopsValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// sopsMACOnlyEncryptedInitialization starts the mac of the files with mac_only_encrypted,
+// the same bytes as sops.
+var sopsMACOnlyEncryptedInitialization = []byte{
+	0x8a, 0x3f, 0xd2, 0xad, 0x54, 0xce, 0x66, 0x52, 0x7b, 0x10, 0x34, 0xf3, 0xd1, 0x47, 0xbe, 0x0b,
+	0x0b, 0x97, 0x5b, 0x3b, 0xf4, 0x4f, 0x72, 0xc6, 0xfd, 0xad, 0xec, 0x81, 0x76, 0xf2, 0x7d, 0x69,
+}
+
+// sopsMetadataOf returns the sops metadata if the YAML or JSON document is encrypted by sops.
+func sopsMetadataOf(data []byte) (*sopsMetadata, bool) {
+	var holder struct {
+		Sops *sopsMetadata `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &holder); err != nil || holder.Sops == nil {
+		return nil, false
+	}
+	if holder.Sops.MAC == "" || holder.Sops.LastModified == "" {
+		return nil, false
+	}
+	return holder.Sops, true
+}
+
+// isSopsFile reports whether the YAML or JSON document is encrypted by sops.
+func isSopsFile(data []byte) bool {
+	_, ok := sopsMetadataOf(data)
+	return ok
+}
+
+// DecryptSops decrypts the sops encrypted YAML or JSON document with the age identities,
+// and returns the plain YAML document. The plaintext is only kept in memory.
+func DecryptSops(data []byte, ageKey string) ([]byte, error) {
+	metadata, ok := sopsMetadataOf(data)
+	if !ok {
+		return nil, errors.New("sops metadata not found")
+	}
+
+	// the comments are removed, the values can't be marked by them
+	if metadata.UnencryptedCommentRegex != "" || metadata.EncryptedCommentRegex != "" {
+		return nil, errors.New("sops comment regexes are not supported")
+	}
+
+	key, err := metadata.dataKey(ageKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []*yaml.Node
+	hash := sha512.New()
+	if metadata.MACOnlyEncrypted {
+		hash.Write(sopsMACOnlyEncryptedInitialization)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("decode sops document failed: %w", err)
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		root := doc.Content[0]
+		if root.Kind != yaml.MappingNode {
+			return nil, errors.New("decode sops document failed: only mapping documents are supported")
+		}
+		// remove the sops metadata
+		for i := 0; i < len(root.Content); i += 2 {
+			if root.Content[i].Value == "sops" {
+				root.Content = append(root.Content[:i], root.Content[i+2:]...)
+				break
+			}
+		}
+
+		if err := metadata.decryptNode(root, nil, key, hash); err != nil {
+			return nil, err
+		}
+		// the flow style of JSON input would be sniffed as JSON by the object decoder
+		blockStyle(root)
+		docs = append(docs, &doc)
+	}
+
+	// verify the integrity of the whole document
+	lastModified, err := time.Parse(time.RFC3339, metadata.LastModified)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sops lastmodified: %w", err)
+	}
+	mac, err := decryptSopsValue(metadata.MAC, key, lastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt sops mac failed: %w", err)
+	}
+	if mac.value != fmt.Sprintf("%X", hash.Sum(nil)) {
+		return nil, errors.New("sops mac mismatch, the file was modified")
+	}
+
+	var out bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			out.WriteString("---\n")
+		}
+		enc := yaml.NewEncoder(&out)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// blockStyle writes the mappings and sequences of the node in the block style.
+func blockStyle(node *yaml.Node) {
+	node.Style &^= yaml.FlowStyle
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// dataKey decrypts the sops data key with the age identities.
+func (m *sopsMetadata) dataKey(ageKey string) ([]byte, error) {
+	if ageKey == "" {
+		return nil, errors.New("age key is required to decrypt sops file")
+	}
+	if len(m.KeyGroups) > 1 {
+		return nil, errors.New("sops shamir key groups are not supported")
+	}
+	identities, err := age.ParseIdentities(strings.NewReader(ageKey))
+	if err != nil {
+		return nil, fmt.Errorf("parse age key failed: %w", err)
+	}
+
+	keys := m.Age
+	for _, group := range m.KeyGroups {
+		keys = append(keys, group.Age...)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("sops file is not encrypted with age")
+	}
+
+	for _, k := range keys {
+		r, err := age.Decrypt(armor.NewReader(strings.NewReader(k.Enc)), identities...)
+		if err != nil {
+			continue
+		}
+		key, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	return nil, errors.New("decrypt sops data key failed: no matching age identity")
+}
+
+// encrypted reports whether the value at the path is encrypted, the same rules as sops.
+func (m *sopsMetadata) encrypted(path []string) bool {
+	encrypted := true
+	if m.UnencryptedSuffix != "" {
+		for _, p := range path {
+			if strings.HasSuffix(p, m.UnencryptedSuffix) {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if m.EncryptedSuffix != "" {
+		encrypted = false
+		for _, p := range path {
+			if strings.HasSuffix(p, m.EncryptedSuffix) {
+				encrypted = true
+				break
+			}
+		}
+	}
+	if m.UnencryptedRegex != "" {
+		for _, p := range path {
+			if matched, _ := regexp.MatchString(m.UnencryptedRegex, p); matched {
+				encrypted = false
+				break
+			}
+		}
+	}
+	if m.EncryptedRegex != "" {
+		encrypted = false
+		for _, p := range path {
+			if matched, _ := regexp.MatchString(m.EncryptedRegex, p); matched {
+				encrypted = true
+				break
+			}
+		}
+	}
+	return encrypted
+}
+
+// decryptNode decrypts the scalar values in place and writes them to the mac hash,
+// walking the document in the same order as sops.
+func (m *sopsMetadata) decryptNode(node *yaml.Node, path []string, key []byte, hash io.Writer) error {
+	// comments may contain encrypted data
+	node.HeadComment, node.LineComment, node.FootComment = "", "", ""
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			node.Content[i].HeadComment, node.Content[i].LineComment, node.Content[i].FootComment = "", "", ""
+			p := append(append([]string{}, path...), node.Content[i].Value)
+			if err := m.decryptNode(node.Content[i+1], p, key, hash); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			if err := m.decryptNode(item, path, key, hash); err != nil {
+				return err
+			}
+		}
+	case yaml.AliasNode:
+		return errors.New("decode sops document failed: aliases are not supported")
+	case yaml.ScalarNode:
+		if node.ShortTag() == "!!null" {
+			return nil
+		}
+
+		if m.encrypted(path) && node.Value != "" {
+			v, err := decryptSopsValue(node.Value, key, strings.Join(path, ":")+":")
+			if err != nil {
+				return fmt.Errorf("decrypt sops value %s failed: %w", strings.Join(path, "."), err)
+			}
+			node.Value, node.Tag, node.Style = v.value, v.tag, 0
+			_, err = hash.Write(v.macBytes())
+			return err
+		}
+		if m.MACOnlyEncrypted {
+			return nil
+		}
+
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		data, err := sopsMacBytes(value)
+		if err != nil {
+			return err
+		}
+		_, err = hash.Write(data)
+		return err
+	}
+	return nil
+}
+
+// sopsValue is a decrypted sops value with its YAML tag.
+type sopsValue struct {
+	value string
+	tag   string
+}
+
+// macBytes returns the bytes of the value used by the sops mac.
+func (v sopsValue) macBytes() []byte {
+	if v.tag == "!!bool" {
+		if b, _ := strconv.ParseBool(v.value); b {
+			return []byte("True")
+		}
+		return []byte("False")
+	}
+	return []byte(v.value)
+}
+
+// sopsMacBytes returns the bytes of a plain value used by the sops mac.
+func sopsMacBytes(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), nil
+	case int:
+		return []byte(strconv.Itoa(v)), nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), nil
+	case bool:
+		if v {
+			return []byte("True"), nil
+		}
+		return []byte("False"), nil
+	case []byte:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported sops value type %T", v)
+}
+
+// decryptSopsValue decrypts an ENC[AES256_GCM,...] value.
+func decryptSopsValue(value string, key []byte, additionalData string) (sopsValue, error) {
+	matches := reSopsValue.FindStringSubmatch(value)
+	if matches == nil {
+		return sopsValue{}, errors.New("invalid encrypted value format")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(matches[1])
+	if err != nil {
+		return sopsValue{}, err
+	}
+	iv, err := base64.StdEncoding.DecodeString(matches[2])
+	if err != nil {
+		return sopsValue{}, err
+	}
+	tag, err := base64.StdEncoding.DecodeString(matches[3])
+	if err != nil {
+		return sopsValue{}, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return sopsValue{}, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return sopsValue{}, err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return sopsValue{}, err
+	}
+
+	result := sopsValue{value: string(plaintext)}
+	switch matches[4] {
+	case "str", "bytes", "comment":
+		result.tag = "!!str"
+	case "int":
+		result.tag = "!!int"
+	case "float":
+		result.tag = "!!float"
+	case "bool":
+		result.tag = "!!bool"
+	default:
+		return sopsValue{}, fmt.Errorf("unknown encrypted value type %q", matches[4])
+	}
+	return result, nil
+}
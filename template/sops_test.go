@@ -0,0 +1,357 @@
+package template
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// encryptSopsValue encrypts the value like sops does.
+func encryptSopsValue(t *testing.T, value, typ string, key []byte, additionalData string) string {
+	t.Helper()
+	iv := make([]byte, 32)
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := gcm.Seal(nil, iv, []byte(value), []byte(additionalData))
+	data, tag := out[:len(out)-gcm.Overhead()], out[len(out)-gcm.Overhead():]
+	return fmt.Sprintf(
+		"ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag),
+		typ,
+	)
+}
+
+// encryptSops encrypts all the values of the plain YAML document with a new age identity,
+// and returns the sops document and the identity.
+func encryptSops(t *testing.T, plain string) (string, string) {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	var enc bytes.Buffer
+	aw := armor.NewWriter(&enc)
+	w, err := age.Encrypt(aw, identity.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(plain), &doc); err != nil {
+		t.Fatal(err)
+	}
+	hash := sha512.New()
+	var walk func(node *yaml.Node, path []string)
+	walk = func(node *yaml.Node, path []string) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				walk(node.Content[i+1], append(append([]string{}, path...), node.Content[i].Value))
+			}
+		case yaml.SequenceNode:
+			for _, item := range node.Content {
+				walk(item, path)
+			}
+		case yaml.ScalarNode:
+			typ := strings.TrimPrefix(node.ShortTag(), "!!")
+			v := sopsValue{value: node.Value, tag: node.ShortTag()}
+			_, _ = hash.Write(v.macBytes())
+			node.Value = encryptSopsValue(t, node.Value, typ, key, strings.Join(path, ":")+":")
+			node.Tag, node.Style = "!!str", 0
+		}
+	}
+	walk(doc.Content[0], nil)
+
+	lastModified := time.Now().UTC().Format(time.RFC3339)
+	mac := encryptSopsValue(t, fmt.Sprintf("%X", hash.Sum(nil)), "str", key, lastModified)
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := yaml.Marshal(map[string]interface{}{
+		"sops": map[string]interface{}{
+			"age": []map[string]string{{
+				"recipient": identity.Recipient().String(),
+				"enc":       enc.String(),
+			}},
+			"lastmodified": lastModified,
+			"mac":          mac,
+			"version":      "3.8.1",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out) + string(metadata), identity.String()
+}
+
+const sopsSecret = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: s3cr3t
+  port: 5432
+  enabled: true
+`
+
+func TestDecryptSops(t *testing.T) {
+	data, key := encryptSops(t, sopsSecret)
+	if !isSopsFile([]byte(data)) {
+		t.Fatal("expected sops file")
+	}
+	if isSopsFile([]byte(sopsSecret)) {
+		t.Fatal("plain file detected as sops file")
+	}
+	if strings.Contains(data, "s3cr3t") {
+		t.Fatal("plaintext found in the encrypted file")
+	}
+
+	plain, err := DecryptSops([]byte(data), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, want map[string]interface{}
+	if err := yaml.Unmarshal(plain, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte(sopsSecret), &want); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("DecryptSops() = %v, want %v", got, want)
+	}
+}
+
+func TestDecryptSopsErrors(t *testing.T) {
+	data, key := encryptSops(t, sopsSecret)
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecryptSops([]byte(data), ""); err == nil {
+		t.Error("expected error without age key")
+	}
+	if _, err := DecryptSops([]byte(data), other.String()); err == nil {
+		t.Error("expected error with the wrong age key")
+	}
+
+	// swap the encrypted values of two keys
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatal(err)
+	}
+	stringData := doc["stringData"].(map[string]interface{})
+	tampered := strings.Replace(data, stringData["port"].(string), stringData["enabled"].(string), 1)
+	if _, err := DecryptSops([]byte(tampered), key); err == nil {
+		t.Error("expected error with the tampered file")
+	}
+}
+
+func TestParseSetSops(t *testing.T) {
+	data, key := encryptSops(t, strings.Replace(sopsSecret, "name: db", `name: "{{ .envs.app }}"`, 1))
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "secret.enc.yaml"), []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	objs, err := ParseSet(
+		[]string{filepath.Join(dir, "*.yaml")},
+		map[string]any{"app": "api"},
+		WithAgeKey(key),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("got %d objects, want 1", len(objs))
+	}
+	if !objs[0].Sensitive {
+		t.Error("expected sensitive object")
+	}
+	if name := objs[0].Obj.GetName(); name != "api" {
+		t.Errorf("name = %q, want api", name)
+	}
+	password := objs[0].Obj.Object["stringData"].(map[string]interface{})["password"]
+	if password != "s3cr3t" {
+		t.Errorf("password = %v, want s3cr3t", password)
+	}
+
+	if _, err := ParseSet([]string{filepath.Join(dir, "*.yaml")}, nil); err == nil {
+		t.Error("expected error without age key")
+	}
+}
+
+// the fixtures in testdata/sops are encrypted by the sops binary with the test age key
+func TestDecryptSopsFixtures(t *testing.T) {
+	key, err := os.ReadFile(filepath.Join("testdata", "sops", "age.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		file  string
+		plain string
+	}{
+		{"all values", "secret.enc.yaml", "secret.yaml"},
+		{"encrypted regex", "secret.regex.enc.yaml", "secret.yaml"},
+		{"mac only encrypted", "configmap.enc.yaml", "configmap.yaml"},
+		{"value types", "values.enc.yaml", "values.yaml"},
+		{"json", "secret.enc.json", "secret.json"},
+		{"multiple documents", "multi.enc.yaml", "multi.yaml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "sops", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain, err := os.ReadFile(filepath.Join("testdata", "sops", tt.plain))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := DecryptSops(data, string(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotDocs, wantDocs := decodeYAMLDocuments(t, got), decodeYAMLDocuments(t, plain)
+			if fmt.Sprint(gotDocs) != fmt.Sprint(wantDocs) {
+				t.Errorf("DecryptSops() = %v, want %v", gotDocs, wantDocs)
+			}
+		})
+	}
+}
+
+// decodeYAMLDocuments decodes all the documents of the YAML or JSON data.
+func decodeYAMLDocuments(t *testing.T, data []byte) []interface{} {
+	t.Helper()
+	var docs []interface{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return docs
+			}
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+	}
+}
+
+func TestDecryptSopsMACOnlyEncrypted(t *testing.T) {
+	key, err := os.ReadFile(filepath.Join("testdata", "sops", "age.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "sops", "configmap.enc.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the plain values are not covered by the mac
+	plain := strings.Replace(string(data), "LOG_LEVEL: debug", "LOG_LEVEL: info", 1)
+	if _, err := DecryptSops([]byte(plain), string(key)); err != nil {
+		t.Errorf("DecryptSops() with a changed plain value: %v", err)
+	}
+
+	// the mac must match the setting
+	disabled := strings.Replace(string(data), "mac_only_encrypted: true", "mac_only_encrypted: false", 1)
+	if _, err := DecryptSops([]byte(disabled), string(key)); err == nil {
+		t.Error("expected mac mismatch without mac_only_encrypted")
+	}
+}
+
+func TestDecryptSopsMultipleDocuments(t *testing.T) {
+	key, err := os.ReadFile(filepath.Join("testdata", "sops", "age.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "sops", "multi.enc.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the mac covers all the documents, swap the passwords of the two documents
+	docs := decodeYAMLDocuments(t, data)
+	first := docs[0].(map[string]interface{})["stringData"].(map[string]interface{})["password"].(string)
+	second := docs[1].(map[string]interface{})["stringData"].(map[string]interface{})["password"].(string)
+	tampered := strings.NewReplacer(first, second, second, first).Replace(string(data))
+	if _, err := DecryptSops([]byte(tampered), string(key)); err == nil {
+		t.Error("expected error with the tampered document")
+	}
+}
+
+func TestParseSetSopsFixtures(t *testing.T) {
+	key, err := os.ReadFile(filepath.Join("testdata", "sops", "age.key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objs, err := ParseSet(
+		[]string{
+			filepath.Join("testdata", "sops", "secret.enc.json"),
+			filepath.Join("testdata", "sops", "multi.enc.yaml"),
+		},
+		nil,
+		WithAgeKey(string(key)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{}
+	for _, obj := range objs {
+		if !obj.Sensitive {
+			t.Errorf("expected sensitive object %s", obj.Obj.GetName())
+		}
+		names = append(names, obj.Obj.GetName())
+	}
+	if fmt.Sprint(names) != "[db db cache]" {
+		t.Errorf("names = %v, want [db db cache]", names)
+	}
+}
@@ -0,0 +1,316 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// stdinTemplate is the template name used for the content read from stdin.
+const stdinTemplate = "stdin"
+
+// defaultInclude are the files loaded from a template directory.
+var defaultInclude = []string{"*.yaml", "*.yml", "*.json", "*.jsonnet", "_*.tpl"}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// templateSources resolves the template files and the content read from stdin or URLs.
+type templateSources struct {
+	files    []string
+	contents map[string][]byte
+	seen     map[string]bool
+}
+
+// add appends the file once, the first occurrence wins.
+func (s *templateSources) add(file string) {
+	if s.seen[file] {
+		return
+	}
+	s.seen[file] = true
+	s.files = append(s.files, file)
+}
+
+// read returns the content of the template.
+func (s *templateSources) read(file string) ([]byte, error) {
+	if content, ok := s.contents[file]; ok {
+		return content, nil
+	}
+	return os.ReadFile(file)
+}
+
+// resolveTemplates expands the templates to a list of files in a deterministic order.
+// A template can be a glob pattern (supporting **), a directory loaded recursively,
+// - for stdin, or a http(s) URL with an optional #sha256=<hex> checksum.
+// The files of each template are sorted, duplicates are only loaded once.
+func resolveTemplates(templates []string, o *options) (*templateSources, error) {
+	s := &templateSources{
+		contents: map[string][]byte{},
+		seen:     map[string]bool{},
+	}
+
+	for _, template := range templates {
+		var files []string
+		switch {
+		case template == "-":
+			content, err := io.ReadAll(o.stdin)
+			if err != nil {
+				return nil, fmt.Errorf("read template from stdin failed: %w", err)
+			}
+			s.contents[stdinTemplate] = content
+			files = []string{stdinTemplate}
+		case isURL(template):
+			name, content, err := fetchTemplate(template)
+			if err != nil {
+				return nil, err
+			}
+			s.contents[name] = content
+			files = []string{name}
+		case isDir(template):
+			var err error
+			files, err = walkTemplates(template, o.include, o.exclude)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			var err error
+			files, err = globTemplates(template)
+			if err != nil {
+				return nil, fmt.Errorf("invalid template pattern %q: %w", template, err)
+			}
+		}
+
+		if len(files) == 0 {
+			if !o.allowMissing {
+				return nil, fmt.Errorf("template pattern %q matched no files", template)
+			}
+			log.Warn().
+				Str("pattern", template).
+				Msg("template pattern matched no files")
+			continue
+		}
+		for _, file := range files {
+			s.add(file)
+		}
+	}
+
+	return s, nil
+}
+
+func isURL(template string) bool {
+	return strings.HasPrefix(template, "http://") || strings.HasPrefix(template, "https://")
+}
+
+func isDir(template string) bool {
+	info, err := os.Stat(template)
+	return err == nil && info.IsDir()
+}
+
+// fetchTemplate downloads the template and verifies the #sha256=<hex> checksum if any.
+// The returned name is the URL without the fragment.
+func fetchTemplate(rawURL string) (string, []byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid template url %q: %w", rawURL, err)
+	}
+	checksum := ""
+	if u.Fragment != "" {
+		v, ok := strings.CutPrefix(u.Fragment, "sha256=")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid template url %q: expected #sha256=<hex> checksum", rawURL)
+		}
+		checksum = strings.ToLower(v)
+		u.Fragment = ""
+	}
+	name := u.String()
+
+	resp, err := httpClient.Get(name)
+	if err != nil {
+		return "", nil, fmt.Errorf("download template %s failed: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("download template %s failed: %s", name, resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("download template %s failed: %w", name, err)
+	}
+
+	if checksum != "" {
+		sum := sha256.Sum256(content)
+		if actual := hex.EncodeToString(sum[:]); actual != checksum {
+			return "", nil, fmt.Errorf("template %s checksum mismatch: expected %s, got %s", name, checksum, actual)
+		}
+	}
+	return name, content, nil
+}
+
+// walkTemplates returns the files in the directory recursively, in lexical order.
+// Files are loaded if they match one of the include patterns and none of the exclude
+// patterns, matched against the path relative to the directory or the base name.
+func walkTemplates(dir string, include, exclude []string) ([]string, error) {
+	if len(include) == 0 {
+		include = defaultInclude
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+
+		excluded, err := matchAny(exclude, rel)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if excluded {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if excluded {
+			return nil
+		}
+		included, err := matchAny(include, rel)
+		if err != nil {
+			return err
+		}
+		if included {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read template directory %s failed: %w", dir, err)
+	}
+	return files, nil
+}
+
+// globTemplates returns the files matching the pattern, supporting ** for any directories.
+func globTemplates(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+
+	// walk from the longest directory without meta characters
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	i := 0
+	for i < len(segments) && !hasMeta(segments[i]) {
+		i++
+	}
+	root := filepath.FromSlash(strings.Join(segments[:i], "/"))
+	if i == 0 {
+		root = "."
+	} else if root == "" {
+		root = string(filepath.Separator)
+	}
+	rest := strings.Join(segments[i:], "/")
+	if _, err := matchPattern(rest, ""); err != nil {
+		return nil, err
+	}
+
+	var files []string
+	err := filepath.WalkDir(root, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && file == root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		ok, err := matchPattern(rest, filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		if ok {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func hasMeta(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+// matchAny reports whether the slash separated path or its base name matches any pattern.
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := matchPattern(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		if !ok && !strings.Contains(pattern, "/") {
+			ok, _ = path.Match(pattern, path.Base(name))
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchPattern reports whether the slash separated name matches the pattern,
+// where a ** segment matches zero or more directories.
+func matchPattern(pattern, name string) (bool, error) {
+	var names []string
+	if name != "" {
+		names = strings.Split(name, "/")
+	}
+	return matchSegments(strings.Split(pattern, "/"), names)
+}
+
+func matchSegments(pattern, names []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				ok, err := matchSegments(pattern[1:], names[i:])
+				if ok || err != nil {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(names) == 0 {
+			// validate the rest of the pattern
+			_, err := path.Match(pattern[0], "")
+			return false, err
+		}
+		ok, err := path.Match(pattern[0], names[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		pattern, names = pattern[1:], names[1:]
+	}
+	return len(names) == 0, nil
+}
@@ -0,0 +1,146 @@
+package template
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestResolveTemplates(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"b.yaml":                "",
+		"a.yml":                 "",
+		"README.md":             "",
+		"_helpers.tpl":          "",
+		"apps/web/deploy.yaml":  "",
+		"apps/web/svc.json":     "",
+		"apps/test/deploy.yaml": "",
+		"apps/lib.libsonnet":    "",
+	})
+	join := func(names ...string) []string {
+		files := make([]string, 0, len(names))
+		for _, name := range names {
+			files = append(files, filepath.Join(dir, filepath.FromSlash(name)))
+		}
+		return files
+	}
+
+	tests := []struct {
+		name      string
+		templates []string
+		opts      []Option
+		want      []string
+	}{
+		{
+			name:      "directory",
+			templates: []string{dir},
+			want:      join("_helpers.tpl", "a.yml", "apps/test/deploy.yaml", "apps/web/deploy.yaml", "apps/web/svc.json", "b.yaml"),
+		},
+		{
+			name:      "directory include and exclude",
+			templates: []string{dir},
+			opts:      []Option{WithInclude("*.yaml"), WithExclude("test")},
+			want:      join("apps/web/deploy.yaml", "b.yaml"),
+		},
+		{
+			name:      "exclude relative path",
+			templates: []string{dir},
+			opts:      []Option{WithExclude("apps/**/*.json", "*.yml")},
+			want:      join("_helpers.tpl", "apps/test/deploy.yaml", "apps/web/deploy.yaml", "b.yaml"),
+		},
+		{
+			name:      "double star glob",
+			templates: []string{filepath.Join(dir, "**", "*.yaml")},
+			want:      join("apps/test/deploy.yaml", "apps/web/deploy.yaml", "b.yaml"),
+		},
+		{
+			name:      "double star in the middle",
+			templates: []string{filepath.Join(dir, "apps", "**", "deploy.yaml")},
+			want:      join("apps/test/deploy.yaml", "apps/web/deploy.yaml"),
+		},
+		{
+			name:      "deduplicate keeps the first occurrence",
+			templates: []string{filepath.Join(dir, "b.yaml"), filepath.Join(dir, "*.y*ml")},
+			want:      join("b.yaml", "a.yml"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources, err := resolveTemplates(tt.templates, newOptions(tt.opts...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(sources.files, tt.want) {
+				t.Errorf("resolveTemplates() = %v, want %v", sources.files, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSetStdinAndURL(t *testing.T) {
+	configMap := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .envs.name }}\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cm.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(configMap))
+	}))
+	defer server.Close()
+
+	sum := sha256.Sum256([]byte(configMap))
+	checksum := hex.EncodeToString(sum[:])
+
+	objs, err := ParseSet(
+		[]string{"-", server.URL + "/cm.yaml#sha256=" + checksum},
+		map[string]any{"name": "app"},
+		withStdin(strings.NewReader(configMap)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("got %d objects, want 2", len(objs))
+	}
+	if objs[0].TplPath != "stdin" {
+		t.Errorf("TplPath = %q, want stdin", objs[0].TplPath)
+	}
+	if objs[1].TplPath != server.URL+"/cm.yaml" {
+		t.Errorf("TplPath = %q, want %s/cm.yaml", objs[1].TplPath, server.URL)
+	}
+	if objs[1].Obj.GetName() != "app" {
+		t.Errorf("name = %q, want app", objs[1].Obj.GetName())
+	}
+
+	errs := map[string]string{
+		server.URL + "/cm.yaml#sha256=" + strings.Repeat("0", 64): "checksum mismatch",
+		server.URL + "/cm.yaml#md5=abc":                           "expected #sha256=<hex> checksum",
+		server.URL + "/missing.yaml":                              "404 Not Found",
+	}
+	for url, want := range errs {
+		if _, err := ParseSet([]string{url}, map[string]any{}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseSet(%s) error = %v, want %q", url, err, want)
+		}
+	}
+}
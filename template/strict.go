@@ -0,0 +1,153 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// MissingKeyError is returned in strict mode when a template
+// refers to a variable which is not defined.
+type MissingKeyError struct {
+	File        string
+	Line        int
+	Key         string
+	Suggestions []string
+}
+
+func (e *MissingKeyError) Error() string {
+	msg := fmt.Sprintf("%s:%d: variable %s is not defined", e.File, e.Line, e.Key)
+	if len(e.Suggestions) > 0 {
+		msg += ", did you mean " + strings.Join(e.Suggestions, ", ") + "?"
+	}
+	return msg
+}
+
+var reMissingKey = regexp.MustCompile(`^:(\d+):\d+: executing ".*" at <(.*)>: map has no entry for key "(.*)"$`)
+
+// missingKeyError converts the missing key error of text/template to a MissingKeyError,
+// other errors are returned unchanged.
+func missingKeyError(err error, names []string, data map[string]any) error {
+	var execErr template.ExecError
+	if !errors.As(err, &execErr) {
+		return err
+	}
+
+	// the innermost error, for example from a helper called by include
+	msg := err.Error()
+	if i := strings.LastIndex(msg, "template: "); i > 0 {
+		msg = msg[i:]
+	}
+
+	var name string
+	var matches []string
+	for _, n := range names {
+		prefix := "template: " + n
+		if !strings.HasPrefix(msg, prefix) {
+			continue
+		}
+		if matches = reMissingKey.FindStringSubmatch(strings.TrimPrefix(msg, prefix)); matches != nil {
+			name = n
+			break
+		}
+	}
+	if matches == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(matches[1])
+	node, key := matches[2], matches[3]
+
+	result := &MissingKeyError{
+		File: name,
+		Line: line,
+		Key:  node,
+	}
+
+	// walk to the map which misses the key, which may be any key of the chain,
+	// like image of .Values.image.tag
+	if !strings.HasPrefix(node, ".") {
+		return result
+	}
+	path := strings.Split(strings.TrimPrefix(node, "."), ".")
+	current := data
+	for i, k := range path {
+		if _, ok := current[k]; !ok {
+			if k != key {
+				return result
+			}
+			prefix := "." + strings.Join(path[:i], ".")
+			if i > 0 {
+				prefix += "."
+			}
+			result.Key = prefix + key
+			for _, s := range suggestKeys(key, current) {
+				result.Suggestions = append(result.Suggestions, prefix+s)
+			}
+			return result
+		}
+		next, ok := current[k].(map[string]any)
+		if !ok {
+			return result
+		}
+		current = next
+	}
+	return result
+}
+
+// suggestKeys returns up to three keys of the map similar to the given key.
+func suggestKeys(key string, m map[string]any) []string {
+	type candidate struct {
+		key      string
+		distance int
+	}
+
+	maxDistance := len(key) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	candidates := []candidate{}
+	for k := range m {
+		if d := levenshtein(strings.ToLower(key), strings.ToLower(k)); d <= maxDistance {
+			candidates = append(candidates, candidate{key: k, distance: d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].key < candidates[j].key
+	})
+
+	result := []string{}
+	for i := 0; i < len(candidates) && i < 3; i++ {
+		result = append(result, candidates[i].key)
+	}
+	return result
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
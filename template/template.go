@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"text/template"
 
+	"github.com/appleboy/deploy-k8s/redact"
+
+	"github.com/rs/zerolog/log"
+
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	pkgruntime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -25,95 +27,166 @@ type KubeObject struct {
 	TplPath string
 	GVK     schema.GroupVersionKind
 	Obj     *unstructured.Unstructured
+	// Sensitive is true if the object is decrypted from a sops file,
+	// the content must never be printed.
+	Sensitive bool
 }
 
+// PrettyString returns the indented JSON of the object, the data of Secrets is masked.
 func (k *KubeObject) PrettyString() string {
+	data := []byte(k.TplData)
+	if k.Obj != nil && redact.IsSecret(k.Obj.Object) {
+		data, _ = json.Marshal(redact.Object(k.Obj.Object))
+	}
 	var prettyJSON bytes.Buffer
-	_ = json.Indent(&prettyJSON, []byte(k.TplData), "", "  ")
+	_ = json.Indent(&prettyJSON, data, "", "  ")
 	return prettyJSON.String()
 }
 
-var (
-	reDronePlugin  = regexp.MustCompile(`^PLUGIN_(.*)=(.*)`)
-	reDroneVar     = regexp.MustCompile(`^(DRONE_.*)=(.*)`)
-	reGitHubAction = regexp.MustCompile(`^INPUT_(.*)=(.*)`)
-	reGitHubVar    = regexp.MustCompile(`^(GITHUB_.*)=(.*)`)
-)
-
 // GetAllEnviroment returns all environment variables.
+//
+// Deprecated: use LoadEnvironment, GetAllEnviroment returns the variables of the DefaultEnvProfiles.
 func GetAllEnviroment() map[string]any {
-	envs := make(map[string]any)
-	for _, e := range os.Environ() {
-		// Drone CI
-		if reDronePlugin.MatchString(e) {
-			matches := reDronePlugin.FindStringSubmatch(e)
-			key := strings.ToLower(matches[1])
-			envs[key] = matches[2]
-			continue
-		}
-		// Drone CI
-		if reDroneVar.MatchString(e) {
-			matches := reDroneVar.FindStringSubmatch(e)
-			key := strings.ToLower(matches[1])
-			envs[key] = matches[2]
-			continue
-		}
-		// GitHub Actions
-		if reGitHubAction.MatchString(e) {
-			matches := reGitHubAction.FindStringSubmatch(e)
-			key := strings.ToLower(matches[1])
-			envs[key] = matches[2]
-			continue
-		}
-		// GitHub Actions
-		if reGitHubVar.MatchString(e) {
-			matches := reGitHubVar.FindStringSubmatch(e)
-			key := strings.ToLower(matches[1])
-			envs[key] = matches[2]
-			continue
-		}
-	}
+	// the default profiles are always known, so LoadEnvironment can't fail
+	envs, _ := LoadEnvironment(EnvConfig{})
 	return envs
 }
 
 // NewTemplate returns a string by template.
-func NewTemplate(format string, data map[string]interface{}) ([]byte, error) {
-	t, err := template.New("message").Parse(format)
-	if err != nil {
+func NewTemplate(format string, data map[string]interface{}, opts ...Option) ([]byte, error) {
+	o := newOptions(opts...)
+	t := template.New(o.name)
+	funcs := funcMap()
+	// include executes a named template and returns the result,
+	// so it can be piped to other functions like nindent.
+	funcs["include"] = func(name string, data any) (string, error) {
+		var buf bytes.Buffer
+		if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	t.Funcs(funcs)
+	if o.strict {
+		t.Option("missingkey=error")
+	}
+
+	for _, h := range o.helpers {
+		if _, err := t.New(h.name).Parse(h.content); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := t.Parse(format); err != nil {
 		return nil, err
 	}
 
 	var tpl bytes.Buffer
 
-	if err := t.Execute(&tpl, map[string]any{
-		"envs": data,
-	}); err != nil {
-		return nil, err
+	root := map[string]any{
+		"envs":   data,
+		"Values": o.values,
+	}
+	if err := t.Execute(&tpl, root); err != nil {
+		return nil, missingKeyError(err, o.templateNames(), root)
 	}
 
 	return tpl.Bytes(), nil
 }
 
 // ParseSet returns a list of unstructured objects.
-func ParseSet(templates []string, envMap map[string]any) ([]*KubeObject, error) {
+func ParseSet(templates []string, envMap map[string]any, opts ...Option) ([]*KubeObject, error) {
 	objects := make([]*KubeObject, 0)
 	fileSets := []string{}
+	helperSets := []string{}
 
-	for _, template := range templates {
-		files, err := filepath.Glob(template)
+	o := newOptions(opts...)
+	sources, err := resolveTemplates(templates, o)
+	if err != nil {
+		return nil, err
+	}
+	// the files of the helper patterns render no resources, even when matched by the templates
+	helperFiles := map[string]bool{}
+	for _, pattern := range o.helperPatterns {
+		files, err := globTemplates(pattern)
 		if err != nil {
+			return nil, fmt.Errorf("invalid template helpers pattern %q: %w", pattern, err)
+		}
+		if len(files) == 0 {
+			if !o.allowMissing {
+				return nil, fmt.Errorf("template helpers pattern %q matched no files", pattern)
+			}
+			log.Warn().
+				Str("pattern", pattern).
+				Msg("template helpers pattern matched no files")
 			continue
 		}
-		fileSets = append(fileSets, files...)
+		for _, file := range files {
+			if !helperFiles[file] {
+				helperFiles[file] = true
+				helperSets = append(helperSets, file)
+			}
+		}
 	}
 
-	for _, template := range fileSets {
-		format, err := os.ReadFile(template)
+	for _, file := range sources.files {
+		if helperFiles[file] {
+			continue
+		}
+		// helper files like _helpers.tpl render no resources
+		if isHelperFile(file) {
+			helperSets = append(helperSets, file)
+			continue
+		}
+		// jsonnet libraries are only imported by other files
+		if isJsonnetLibrary(file) {
+			continue
+		}
+		fileSets = append(fileSets, file)
+	}
+
+	helpers := make([]helper, 0, len(helperSets))
+	for _, file := range helperSets {
+		content, err := sources.read(file)
 		if err != nil {
+			return nil, fmt.Errorf("read template helper failed: %w", err)
+		}
+		helpers = append(helpers, helper{name: file, content: string(content)})
+	}
+	opts = append([]Option{withHelpers(helpers)}, opts...)
+
+	for _, template := range fileSets {
+		if isJsonnetFile(template) {
+			if _, ok := sources.contents[template]; ok {
+				return nil, fmt.Errorf("jsonnet template %s must be a local file", template)
+			}
+			objs, err := ParseJsonnet(template, envMap, o.values)
+			if err != nil {
+				return nil, err
+			}
+			kubeObjs, err := newKubeObjects(template, objs)
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, kubeObjs...)
 			continue
 		}
 
-		tpl, err := NewTemplate(string(format), envMap)
+		format, err := sources.read(template)
+		if err != nil {
+			return nil, fmt.Errorf("read template failed: %w", err)
+		}
+
+		// decrypt sops files in memory before templating
+		sensitive := isSopsFile(format)
+		if sensitive {
+			format, err = DecryptSops(format, o.ageKey)
+			if err != nil {
+				return nil, fmt.Errorf("decrypt %s failed: %w", template, err)
+			}
+		}
+
+		tpl, err := NewTemplate(string(format), envMap, append([]Option{withName(template)}, opts...)...)
 		if err != nil {
 			return nil, err
 		}
@@ -123,25 +196,51 @@ func ParseSet(templates []string, envMap map[string]any) ([]*KubeObject, error)
 			return nil, err
 		}
 
-		for _, obj := range objs {
-			objCopy := obj.DeepCopy()
-			data, err := objCopy.MarshalJSON()
-			if err != nil {
-				return nil, err
-			}
-
-			objects = append(objects, &KubeObject{
-				TplData: string(data),
-				TplPath: template,
-				GVK:     objCopy.GroupVersionKind(),
-				Obj:     objCopy,
-			})
+		kubeObjs, err := newKubeObjects(template, objs)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range kubeObjs {
+			v.Sensitive = sensitive
 		}
+		objects = append(objects, kubeObjs...)
 	}
 
+	log.Info().
+		Strs("files", fileSets).
+		Strs("helpers", helperSets).
+		Int("objects", len(objects)).
+		Msg("load templates success")
+
 	return objects, nil
 }
 
+// newKubeObjects wraps the unstructured objects rendered from the path.
+func newKubeObjects(path string, objs []unstructured.Unstructured) ([]*KubeObject, error) {
+	objects := make([]*KubeObject, 0, len(objs))
+	for _, obj := range objs {
+		objCopy := obj.DeepCopy()
+		data, err := objCopy.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		objects = append(objects, &KubeObject{
+			TplData: string(data),
+			TplPath: path,
+			GVK:     objCopy.GroupVersionKind(),
+			Obj:     objCopy,
+		})
+	}
+	return objects, nil
+}
+
+// isHelperFile reports whether the file only defines shared templates, like _helpers.tpl.
+func isHelperFile(file string) bool {
+	base := filepath.Base(file)
+	return strings.HasPrefix(base, "_") && filepath.Ext(base) == ".tpl"
+}
+
 // ParseObject returns a list of unstructured objects.
 func ParseObject(data []byte) ([]unstructured.Unstructured, error) {
 	var result []unstructured.Unstructured
@@ -1,8 +1,10 @@
 package template
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -208,3 +210,244 @@ metadata:
 		t.Errorf("Expected GVK: v1/ServiceAccount, got: %s/%s", obj3.GVK.GroupVersion(), obj3.GVK.Kind)
 	}
 }
+
+func TestTemplateFuncs(t *testing.T) {
+	data := map[string]interface{}{
+		"name":   "nginx",
+		"empty":  "",
+		"labels": map[string]interface{}{"app": "nginx", "tier": "web"},
+	}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{`{{ .envs.name | quote }}`, `"nginx"`},
+		{`{{ .envs.empty | default "latest" }}`, `latest`},
+		{`{{ .envs.name | b64enc }}`, `bmdpbng=`},
+		{`{{ .envs.name | sha256sum }}`, `5be1ecc7935f1dd85635d4feedaf660594030253cc97c9e9ca3819ffeac36b65`},
+		{`{{ .envs.labels | toYaml }}`, "app: nginx\ntier: web"},
+		{"labels:{{ .envs.labels | toYaml | nindent 2 }}", "labels:\n  app: nginx\n  tier: web"},
+		{`{{ (fromYaml "replicas: 3").replicas }}`, `3`},
+		{`{{ required "name is required" .envs.name }}`, `nginx`},
+	}
+
+	for _, tt := range tests {
+		result, err := NewTemplate(tt.format, data)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", tt.format, err)
+			continue
+		}
+		if string(result) != tt.expected {
+			t.Errorf("Expected result for %s: %q, got: %q", tt.format, tt.expected, result)
+		}
+	}
+
+	if _, err := NewTemplate(`{{ required "image is required" .envs.image }}`, data); err == nil {
+		t.Errorf("Expected error for missing required value")
+	}
+	if _, err := NewTemplate(`{{ required "empty is required" .envs.empty }}`, data); err == nil {
+		t.Errorf("Expected error for empty required value")
+	}
+
+	t.Setenv("PLUGIN_TOKEN", "secret-token")
+	for _, format := range []string{`{{ env "PLUGIN_TOKEN" }}`, `{{ expandenv "$PLUGIN_TOKEN" }}`} {
+		result, err := NewTemplate(format, data)
+		if err == nil || !strings.Contains(err.Error(), "not defined") {
+			t.Errorf("Expected undefined function error for %s, got: %q, %v", format, result, err)
+		}
+	}
+}
+
+func TestNewTemplateStrict(t *testing.T) {
+	data := map[string]interface{}{
+		"app_namespace": "production",
+		"image":         "nginx",
+	}
+	format := "metadata:\n  image: {{ .envs.image }}\n  namespace: {{ .envs.app_namspace }}\n"
+
+	result, err := NewTemplate(format, data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !strings.Contains(string(result), "<no value>") {
+		t.Errorf("Expected <no value> without strict mode, got: %s", result)
+	}
+
+	_, err = NewTemplate(format, data, WithStrict(true), withName("deploy/app.yaml"))
+	var missingErr *MissingKeyError
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("Expected MissingKeyError, got: %v", err)
+	}
+	if missingErr.File != "deploy/app.yaml" || missingErr.Line != 3 || missingErr.Key != ".envs.app_namspace" {
+		t.Errorf("Unexpected error: %+v", missingErr)
+	}
+	if len(missingErr.Suggestions) != 1 || missingErr.Suggestions[0] != ".envs.app_namespace" {
+		t.Errorf("Expected suggestion .envs.app_namespace, got: %v", missingErr.Suggestions)
+	}
+	expected := "deploy/app.yaml:3: variable .envs.app_namspace is not defined, did you mean .envs.app_namespace?"
+	if err.Error() != expected {
+		t.Errorf("Expected error: %s, got: %s", expected, err)
+	}
+
+	_, err = NewTemplate("{{ .Values.image.tg }}", data, WithStrict(true), WithValues(map[string]any{
+		"image": map[string]any{"tag": "v1"},
+	}))
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("Expected MissingKeyError, got: %v", err)
+	}
+	if len(missingErr.Suggestions) != 1 || missingErr.Suggestions[0] != ".Values.image.tag" {
+		t.Errorf("Expected suggestion .Values.image.tag, got: %v", missingErr.Suggestions)
+	}
+
+	// a missing key in the middle of the chain is reported up to the missing key
+	_, err = NewTemplate("{{ .Values.image.tag }}", data, WithStrict(true), WithValues(map[string]any{
+		"imag": map[string]any{"tag": "v1"},
+	}))
+	if !errors.As(err, &missingErr) {
+		t.Fatalf("Expected MissingKeyError, got: %v", err)
+	}
+	if missingErr.Key != ".Values.image" {
+		t.Errorf("Expected key .Values.image, got: %s", missingErr.Key)
+	}
+	if len(missingErr.Suggestions) != 1 || missingErr.Suggestions[0] != ".Values.imag" {
+		t.Errorf("Expected suggestion .Values.imag, got: %v", missingErr.Suggestions)
+	}
+}
+
+func TestParseSetHelpers(t *testing.T) {
+	tempDir := t.TempDir()
+	sharedDir := t.TempDir()
+
+	helpers := []byte(`{{- define "app.labels" -}}
+app: {{ .envs.app_name }}
+tier: web
+{{- end }}`)
+	if err := os.WriteFile(filepath.Join(tempDir, "_helpers.tpl"), helpers, 0o644); err != nil {
+		t.Fatalf("Failed to write _helpers.tpl: %v", err)
+	}
+
+	shared := []byte(`{{- define "app.namespace" -}}{{ .envs.app_namespace }}{{- end }}`)
+	if err := os.WriteFile(filepath.Join(sharedDir, "namespace.tpl"), shared, 0o644); err != nil {
+		t.Fatalf("Failed to write namespace.tpl: %v", err)
+	}
+
+	template1 := []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: configmap1
+  namespace: {{ template "app.namespace" . }}
+  labels:
+    {{- include "app.labels" . | nindent 4 }}
+`)
+	if err := os.WriteFile(filepath.Join(tempDir, "template1.yaml"), template1, 0o644); err != nil {
+		t.Fatalf("Failed to write template1.yaml: %v", err)
+	}
+
+	// only _*.tpl files are helpers, other files starting with _ are applied
+	namespace := []byte(`
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{ template "app.namespace" . }}
+`)
+	if err := os.WriteFile(filepath.Join(tempDir, "_namespace.yaml"), namespace, 0o644); err != nil {
+		t.Fatalf("Failed to write _namespace.yaml: %v", err)
+	}
+
+	objects, err := ParseSet(
+		[]string{filepath.Join(tempDir, "*")},
+		map[string]any{"app_name": "nginx", "app_namespace": "testing"},
+		WithHelpers(filepath.Join(sharedDir, "*.tpl")),
+	)
+	if err != nil {
+		t.Fatalf("Error parsing objects: %v", err)
+	}
+
+	if len(objects) != 2 {
+		t.Fatalf("Expected 2 objects, got: %d", len(objects))
+	}
+	if objects[0].Obj.GetKind() != "Namespace" || objects[0].Obj.GetName() != "testing" {
+		t.Errorf("Expected namespace testing from _namespace.yaml, got: %s/%s", objects[0].Obj.GetKind(), objects[0].Obj.GetName())
+	}
+	objects = objects[1:]
+	obj := objects[0].Obj
+	if obj.GetNamespace() != "testing" {
+		t.Errorf("Expected namespace: testing, got: %s", obj.GetNamespace())
+	}
+	labels := obj.GetLabels()
+	if labels["app"] != "nginx" || labels["tier"] != "web" {
+		t.Errorf("Expected labels from helpers, got: %v", labels)
+	}
+}
+
+func TestParseSetErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cm.yaml"), []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "broken.yaml")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		templates []string
+		opts      []Option
+		want      string
+		objects   int
+	}{
+		{
+			name:      "invalid pattern",
+			templates: []string{filepath.Join(dir, "[")},
+			want:      "invalid template pattern",
+		},
+		{
+			name:      "no matches",
+			templates: []string{filepath.Join(dir, "cm.yaml"), filepath.Join(dir, "typo.yaml")},
+			want:      "matched no files",
+		},
+		{
+			name:      "allow missing",
+			templates: []string{filepath.Join(dir, "cm.yaml"), filepath.Join(dir, "typo.yaml")},
+			opts:      []Option{WithAllowMissing(true)},
+			objects:   1,
+		},
+		{
+			name:      "helpers no matches",
+			templates: []string{filepath.Join(dir, "cm.yaml")},
+			opts:      []Option{WithHelpers(filepath.Join(dir, "*.tpl"))},
+			want:      "template helpers pattern",
+		},
+		{
+			name:      "helpers allow missing",
+			templates: []string{filepath.Join(dir, "cm.yaml")},
+			opts:      []Option{WithHelpers(filepath.Join(dir, "*.tpl")), WithAllowMissing(true)},
+			objects:   1,
+		},
+		{
+			name:      "unreadable file",
+			templates: []string{filepath.Join(dir, "broken.yaml")},
+			want:      "read template failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects, err := ParseSet(tt.templates, map[string]any{}, tt.opts...)
+			if tt.want != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("ParseSet() error = %v, want %q", err, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(objects) != tt.objects {
+				t.Errorf("got %d objects, want %d", len(objects), tt.objects)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package template
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// LoadValues reads the values files, merged in order, then applies
+// the key.path=value overrides.
+func LoadValues(files, sets []string) (map[string]any, error) {
+	values := map[string]any{}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read values file failed: %w", err)
+		}
+		current := map[string]any{}
+		if err := yaml.Unmarshal(data, &current); err != nil {
+			return nil, fmt.Errorf("parse values file %s failed: %w", file, err)
+		}
+		values = mergeValues(values, current)
+	}
+
+	for _, set := range sets {
+		if err := setValue(values, set); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// mergeValues merges src into dst recursively, the values in src win.
+func mergeValues(dst, src map[string]any) map[string]any {
+	for k, v := range src {
+		srcMap, srcOk := v.(map[string]any)
+		dstMap, dstOk := dst[k].(map[string]any)
+		if srcOk && dstOk {
+			dst[k] = mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// setValue sets a key.path=value override in the values.
+func setValue(values map[string]any, set string) error {
+	key, value, ok := strings.Cut(set, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("invalid set value %q, expected key.path=value", set)
+	}
+
+	keys := strings.Split(key, ".")
+	current := values
+	for _, k := range keys[:len(keys)-1] {
+		if k == "" {
+			return fmt.Errorf("invalid set value %q, empty key in path", set)
+		}
+		next, ok := current[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			current[k] = next
+		}
+		current = next
+	}
+
+	last := keys[len(keys)-1]
+	if last == "" {
+		return fmt.Errorf("invalid set value %q, empty key in path", set)
+	}
+	current[last] = parseValue(value)
+	return nil
+}
+
+// parseValue converts the string to bool, int64 or nil if possible.
+func parseValue(v string) any {
+	switch v {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return n
+	}
+	return v
+}
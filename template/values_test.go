@@ -0,0 +1,82 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadValues(t *testing.T) {
+	tempDir := t.TempDir()
+
+	base := []byte(`
+replicas: 1
+image:
+  repository: nginx
+  tag: "1.24"
+ingress:
+  enabled: false
+`)
+	if err := os.WriteFile(filepath.Join(tempDir, "values.yaml"), base, 0o644); err != nil {
+		t.Fatalf("Failed to write values.yaml: %v", err)
+	}
+
+	production := []byte(`
+replicas: 3
+image:
+  tag: "1.25"
+`)
+	if err := os.WriteFile(filepath.Join(tempDir, "values-production.yaml"), production, 0o644); err != nil {
+		t.Fatalf("Failed to write values-production.yaml: %v", err)
+	}
+
+	values, err := LoadValues(
+		[]string{
+			filepath.Join(tempDir, "values.yaml"),
+			filepath.Join(tempDir, "values-production.yaml"),
+		},
+		[]string{"ingress.enabled=true", "ingress.host=example.com", "resources.limits.cpu=500m"},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	format := `{{ .Values.replicas }} {{ .Values.image.repository }}:{{ .Values.image.tag }} ` +
+		`{{ if .Values.ingress.enabled }}{{ .Values.ingress.host }}{{ end }} {{ .Values.resources.limits.cpu }}`
+	result, err := NewTemplate(format, map[string]any{}, WithValues(values))
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := "3 nginx:1.25 example.com 500m"
+	if string(result) != expected {
+		t.Errorf("Expected result: %s, got: %s", expected, result)
+	}
+
+	if _, err := LoadValues([]string{filepath.Join(tempDir, "missing.yaml")}, nil); err == nil {
+		t.Errorf("Expected error for missing values file")
+	}
+	if _, err := LoadValues(nil, []string{"invalid"}); err == nil {
+		t.Errorf("Expected error for invalid set value")
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected any
+	}{
+		{"true", true},
+		{"false", false},
+		{"null", nil},
+		{"3", int64(3)},
+		{"v1.2.3", "v1.2.3"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if result := parseValue(tt.value); result != tt.expected {
+			t.Errorf("Expected %v (%T) for %q, got: %v (%T)", tt.expected, tt.expected, tt.value, result, result)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/appleboy/deploy-k8s/template"
+	"github.com/appleboy/deploy-k8s/validate"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Validate renders the templates and validates the objects against the
+// OpenAPI schemas and the policy rules, without connecting to the cluster.
+func (p *Plugin) Validate() error {
+	kubeObjs, err := p.Objects()
+	if err != nil {
+		return err
+	}
+	// report the schema errors and the policy violations together
+	if err := errors.Join(p.validate(kubeObjs), p.checkPolicies(kubeObjs)); err != nil {
+		return err
+	}
+
+	log.Info().
+		Int("objects", len(kubeObjs)).
+		Msg("validate resources success")
+	return nil
+}
+
+// validate validates the objects and logs every error with the template file and document index.
+func (p *Plugin) validate(kubeObjs []*template.KubeObject) error {
+	v, err := validate.New(
+		validate.WithKubeVersion(p.Config.KubeVersion),
+		validate.WithSchemaLocation(p.Config.SchemaLocation),
+		validate.WithCacheDir(p.Config.SchemaCache),
+		validate.WithCRDs(p.Config.CRDSchemas...),
+	)
+	if err != nil {
+		return err
+	}
+
+	err = v.Validate(kubeObjs)
+	var errs validate.Errors
+	if !errors.As(err, &errs) {
+		return err
+	}
+	for _, e := range errs {
+		log.Error().
+			Str("event", eventValidate).
+			Str("template", e.File).
+			Int("document", e.Index).
+			Str("kind", e.GVK.Kind).
+			Str("name", e.Name).
+			Str("path", e.Path).
+			Msg(e.Message)
+	}
+	return fmt.Errorf("validate resources failed: %d errors", len(errs))
+}
@@ -0,0 +1,59 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Option configures the validator.
+type Option func(*options)
+
+type options struct {
+	version  string
+	location string
+	cacheDir string
+	crds     []string
+}
+
+func newOptions(opts ...Option) *options {
+	o := &options{}
+	if dir, err := os.UserCacheDir(); err == nil {
+		o.cacheDir = filepath.Join(dir, "deploy-k8s", "openapi")
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithKubeVersion sets the kubernetes version like v1.29.0, the schemas
+// missing in the local directory are downloaded into the cache.
+func WithKubeVersion(version string) Option {
+	return func(o *options) {
+		o.version = version
+	}
+}
+
+// WithSchemaLocation loads the schemas from a local directory with the files
+// of api/openapi-spec/v3 in the kubernetes repository, like apis__apps__v1_openapi.json.
+func WithSchemaLocation(dir string) Option {
+	return func(o *options) {
+		o.location = dir
+	}
+}
+
+// WithCacheDir sets the directory of the downloaded schemas.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		if dir != "" {
+			o.cacheDir = dir
+		}
+	}
+}
+
+// WithCRDs loads the schemas of the custom resource definitions in the files matching the glob patterns.
+func WithCRDs(patterns ...string) Option {
+	return func(o *options) {
+		o.crds = append(o.crds, patterns...)
+	}
+}
@@ -0,0 +1,165 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// quantityRef is the schema of resource quantities, which accept strings and numbers.
+const quantityRef = "io.k8s.apimachinery.pkg.api.resource.Quantity"
+
+// Schema is the subset of an OpenAPI v3 schema used to validate the objects.
+type Schema struct {
+	Ref                   string             `json:"$ref,omitempty"`
+	Type                  string             `json:"type,omitempty"`
+	Properties            map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties  *SchemaOrBool      `json:"additionalProperties,omitempty"`
+	Items                 *Schema            `json:"items,omitempty"`
+	Required              []string           `json:"required,omitempty"`
+	AllOf                 []*Schema          `json:"allOf,omitempty"`
+	IntOrString           bool               `json:"x-kubernetes-int-or-string,omitempty"`
+	PreserveUnknownFields bool               `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	EmbeddedResource      bool               `json:"x-kubernetes-embedded-resource,omitempty"`
+	GroupVersionKind      []groupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+
+	// quantity is set when the schema refers to a resource quantity
+	quantity bool
+}
+
+// SchemaOrBool is the value of additionalProperties, a schema or a boolean.
+type SchemaOrBool struct {
+	Allows bool
+	Schema *Schema
+}
+
+// UnmarshalJSON decodes a schema or a boolean.
+func (s *SchemaOrBool) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &s.Allows); err == nil {
+		return nil
+	}
+	s.Allows = true
+	return json.Unmarshal(data, &s.Schema)
+}
+
+type groupVersionKind struct {
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+// document is an OpenAPI v3 document of a group version.
+type document struct {
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+
+	// kinds maps the group version kinds to the schema names
+	kinds map[schema.GroupVersionKind]string
+}
+
+// parseDocument decodes the OpenAPI v3 document and indexes its kinds.
+func parseDocument(data []byte) (*document, error) {
+	doc := &document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	doc.kinds = map[schema.GroupVersionKind]string{}
+	for name, s := range doc.Components.Schemas {
+		for _, gvk := range s.GroupVersionKind {
+			doc.kinds[schema.GroupVersionKind{
+				Group:   gvk.Group,
+				Version: gvk.Version,
+				Kind:    gvk.Kind,
+			}] = name
+		}
+	}
+	return doc, nil
+}
+
+// schemaFileName returns the file name of the group version in the kubernetes
+// repository, like api__v1_openapi.json or apis__apps__v1_openapi.json.
+func schemaFileName(gv schema.GroupVersion) string {
+	if gv.Group == "" {
+		return fmt.Sprintf("api__%s_openapi.json", gv.Version)
+	}
+	return fmt.Sprintf("apis__%s__%s_openapi.json", gv.Group, gv.Version)
+}
+
+// resolver follows the $ref and merges the allOf schemas of a document.
+type resolver struct {
+	components map[string]*Schema
+	cache      map[*Schema]*Schema
+}
+
+func newResolver(components map[string]*Schema) *resolver {
+	return &resolver{
+		components: components,
+		cache:      map[*Schema]*Schema{},
+	}
+}
+
+// resolve returns the schema with the $ref and allOf schemas merged.
+func (r *resolver) resolve(s *Schema) (*Schema, error) {
+	if s.Ref == "" && len(s.AllOf) == 0 {
+		return s, nil
+	}
+	if v, ok := r.cache[s]; ok {
+		return v, nil
+	}
+
+	merged := *s
+	merged.Ref, merged.AllOf = "", nil
+	parts := append([]*Schema{}, s.AllOf...)
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		target, ok := r.components[name]
+		if !ok {
+			return nil, fmt.Errorf("schema reference %s not found", s.Ref)
+		}
+		if name == quantityRef {
+			merged.quantity = true
+		}
+		parts = append([]*Schema{target}, parts...)
+	}
+
+	for _, part := range parts {
+		p, err := r.resolve(part)
+		if err != nil {
+			return nil, err
+		}
+		merged.merge(p)
+	}
+	r.cache[s] = &merged
+	return &merged, nil
+}
+
+// merge adds the constraints of the other schema.
+func (s *Schema) merge(o *Schema) {
+	if s.Type == "" {
+		s.Type = o.Type
+	}
+	if len(o.Properties) > 0 {
+		props := make(map[string]*Schema, len(s.Properties)+len(o.Properties))
+		for k, v := range o.Properties {
+			props[k] = v
+		}
+		for k, v := range s.Properties {
+			props[k] = v
+		}
+		s.Properties = props
+	}
+	if s.AdditionalProperties == nil {
+		s.AdditionalProperties = o.AdditionalProperties
+	}
+	if s.Items == nil {
+		s.Items = o.Items
+	}
+	s.Required = append(append([]string{}, s.Required...), o.Required...)
+	s.IntOrString = s.IntOrString || o.IntOrString
+	s.PreserveUnknownFields = s.PreserveUnknownFields || o.PreserveUnknownFields
+	s.EmbeddedResource = s.EmbeddedResource || o.EmbeddedResource
+	s.quantity = s.quantity || o.quantity
+}
@@ -0,0 +1,472 @@
+package validate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/appleboy/deploy-k8s/template"
+
+	"github.com/rs/zerolog/log"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// schemaURL is the location of the OpenAPI v3 schemas of a kubernetes version.
+var schemaURL = "https://raw.githubusercontent.com/kubernetes/kubernetes/%s/api/openapi-spec/v3/%s"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// BundledVersion is the kubernetes version of the bundled schemas, they are used
+// without download for the kube versions of the same minor version.
+const BundledVersion = "v1.29.1"
+
+// bundledSchemas are the gzipped schemas of the stable group versions of BundledVersion.
+//
+//go:embed schemas
+var bundledSchemas embed.FS
+
+// Error is a validation error of an object.
+type Error struct {
+	// File is the template which produced the object, and Index the document in the file.
+	File    string
+	Index   int
+	GVK     schema.GroupVersionKind
+	Name    string
+	Path    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s[%d] %s/%s: %s: %s", e.File, e.Index, e.GVK.Kind, e.Name, e.Path, e.Message)
+}
+
+// Errors are the validation errors of all the objects.
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Validator validates the objects against the OpenAPI v3 schemas of a kubernetes version,
+// and the schemas of custom resource definitions.
+type Validator struct {
+	opts      *options
+	crds      map[schema.GroupVersionKind]*Schema
+	documents map[schema.GroupVersion]*document
+}
+
+// New returns a validator, the custom resource definitions are loaded from the files.
+func New(opts ...Option) (*Validator, error) {
+	v := &Validator{
+		opts:      newOptions(opts...),
+		crds:      map[schema.GroupVersionKind]*Schema{},
+		documents: map[schema.GroupVersion]*document{},
+	}
+	// every object would be skipped
+	if v.opts.version == "" && v.opts.location == "" {
+		return nil, errors.New("kube version or schema location is required to validate")
+	}
+	for _, pattern := range v.opts.crds {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid crd schemas pattern %q: %w", pattern, err)
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("crd schemas pattern %q matched no files", pattern)
+		}
+		for _, file := range files {
+			if err := v.loadCRDs(file); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return v, nil
+}
+
+// Validate validates the objects and returns Errors if any object is invalid.
+// Objects without schema are skipped with a warning.
+func (v *Validator) Validate(objs []*template.KubeObject) error {
+	var errs Errors
+	index := map[string]int{}
+	for _, obj := range objs {
+		i := index[obj.TplPath]
+		index[obj.TplPath]++
+
+		s, r, err := v.schema(obj.GVK)
+		if err != nil {
+			return err
+		}
+		if s == nil {
+			log.Warn().
+				Str("template", obj.TplPath).
+				Str("apiVersion", obj.GVK.GroupVersion().String()).
+				Str("kind", obj.GVK.Kind).
+				Str("name", obj.Obj.GetName()).
+				Msg("schema not found, skip validation")
+			continue
+		}
+
+		w := &walker{resolver: r}
+		if err := w.validate(s, obj.Obj.Object, "", true); err != nil {
+			return err
+		}
+		for _, e := range w.errs {
+			e.File, e.Index, e.GVK, e.Name = obj.TplPath, i, obj.GVK, obj.Obj.GetName()
+			errs = append(errs, e)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// schema returns the schema of the kind, or nil if not found.
+func (v *Validator) schema(gvk schema.GroupVersionKind) (*Schema, *resolver, error) {
+	if s, ok := v.crds[gvk]; ok {
+		return s, newResolver(nil), nil
+	}
+
+	gv := gvk.GroupVersion()
+	doc, ok := v.documents[gv]
+	if !ok {
+		data, err := v.readSchemaFile(schemaFileName(gv))
+		if err != nil {
+			return nil, nil, err
+		}
+		if data != nil {
+			doc, err = parseDocument(data)
+			if err != nil {
+				return nil, nil, fmt.Errorf("decode schema of %s failed: %w", gv, err)
+			}
+		}
+		v.documents[gv] = doc
+	}
+	if doc == nil {
+		return nil, nil, nil
+	}
+	name, ok := doc.kinds[gvk]
+	if !ok {
+		return nil, nil, nil
+	}
+	return doc.Components.Schemas[name], newResolver(doc.Components.Schemas), nil
+}
+
+// readSchemaFile reads the schema file from the local directory, the bundled schemas or the cache,
+// and downloads it into the cache for the kubernetes version. It returns nil if not found.
+func (v *Validator) readSchemaFile(name string) ([]byte, error) {
+	if v.opts.location != "" {
+		data, err := os.ReadFile(filepath.Join(v.opts.location, name))
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return data, err
+		}
+	}
+	if v.opts.version == "" {
+		return nil, nil
+	}
+	if minorVersion(v.opts.version) == minorVersion(BundledVersion) {
+		data, err := readBundledSchema(name)
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return data, err
+		}
+	}
+
+	cache := ""
+	if v.opts.cacheDir != "" {
+		cache = filepath.Join(v.opts.cacheDir, v.opts.version, name)
+		if data, err := os.ReadFile(cache); err == nil {
+			return data, nil
+		}
+	}
+
+	url := fmt.Sprintf(schemaURL, v.opts.version, name)
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("download schema %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download schema %s failed: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("download schema %s failed: %w", url, err)
+	}
+
+	if cache != "" {
+		if err := os.MkdirAll(filepath.Dir(cache), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(cache, data, 0o644); err != nil {
+			return nil, err
+		}
+	}
+	log.Debug().
+		Str("url", url).
+		Msg("download schema success")
+	return data, nil
+}
+
+// readBundledSchema reads the bundled schema file.
+func readBundledSchema(name string) ([]byte, error) {
+	data, err := bundledSchemas.ReadFile("schemas/" + BundledVersion + "/" + name + ".gz")
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("read bundled schema %s failed: %w", name, err)
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// minorVersion returns the major and minor version like v1.29 of a version like v1.29.0 or 1.29.
+func minorVersion(version string) string {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return "v" + parts[0] + "." + parts[1]
+}
+
+// loadCRDs loads the schemas of the custom resource definitions in the YAML or JSON file.
+func (v *Validator) loadCRDs(file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("read crd schemas failed: %w", err)
+	}
+	objs, err := template.ParseObject(content)
+	if err != nil {
+		return fmt.Errorf("read crd schemas %s failed: %w", file, err)
+	}
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		if gvk.Group != "apiextensions.k8s.io" || gvk.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		var crd struct {
+			Spec struct {
+				Group string `json:"group"`
+				Names struct {
+					Kind string `json:"kind"`
+				} `json:"names"`
+				Versions []struct {
+					Name   string `json:"name"`
+					Schema struct {
+						OpenAPIV3Schema *Schema `json:"openAPIV3Schema"`
+					} `json:"schema"`
+				} `json:"versions"`
+			} `json:"spec"`
+		}
+		data, err := obj.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &crd); err != nil {
+			return fmt.Errorf("decode crd %s failed: %w", obj.GetName(), err)
+		}
+		for _, version := range crd.Spec.Versions {
+			if version.Schema.OpenAPIV3Schema == nil {
+				continue
+			}
+			v.crds[schema.GroupVersionKind{
+				Group:   crd.Spec.Group,
+				Version: version.Name,
+				Kind:    crd.Spec.Names.Kind,
+			}] = version.Schema.OpenAPIV3Schema
+		}
+	}
+	return nil
+}
+
+// walker validates a value against a schema and collects the errors.
+type walker struct {
+	resolver *resolver
+	errs     []*Error
+}
+
+func (w *walker) errorf(path, format string, args ...interface{}) {
+	if path == "" {
+		path = "."
+	}
+	w.errs = append(w.errs, &Error{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate validates the value, root is true for the object itself.
+func (w *walker) validate(s *Schema, value interface{}, path string, root bool) error {
+	// null is the same as an unset field
+	if value == nil {
+		return nil
+	}
+	s, err := w.resolver.resolve(s)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case s.IntOrString:
+		if !isInteger(value) && !isString(value) {
+			w.errorf(path, "expected integer or string, got %s", typeOf(value))
+		}
+		return nil
+	case s.quantity:
+		if !isNumber(value) && !isString(value) {
+			w.errorf(path, "expected quantity, got %s", typeOf(value))
+		}
+		return nil
+	}
+
+	switch s.Type {
+	case "object":
+		return w.validateObject(s, value, path, root)
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			w.errorf(path, "expected array, got %s", typeOf(value))
+			return nil
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range items {
+			if err := w.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), false); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if !isString(value) {
+			w.errorf(path, "expected string, got %s", typeOf(value))
+		}
+	case "integer":
+		if !isInteger(value) {
+			w.errorf(path, "expected integer, got %s", typeOf(value))
+		}
+	case "number":
+		if !isNumber(value) {
+			w.errorf(path, "expected number, got %s", typeOf(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			w.errorf(path, "expected boolean, got %s", typeOf(value))
+		}
+	case "":
+		if len(s.Properties) > 0 {
+			return w.validateObject(s, value, path, root)
+		}
+	}
+	return nil
+}
+
+func (w *walker) validateObject(s *Schema, value interface{}, path string, root bool) error {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		w.errorf(path, "expected object, got %s", typeOf(value))
+		return nil
+	}
+
+	for _, field := range s.Required {
+		if _, ok := obj[field]; !ok {
+			w.errorf(joinPath(path, field), "required field is missing")
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if prop, ok := s.Properties[k]; ok {
+			if err := w.validate(prop, obj[k], joinPath(path, k), false); err != nil {
+				return err
+			}
+			continue
+		}
+		if ap := s.AdditionalProperties; ap != nil && ap.Allows {
+			if ap.Schema != nil {
+				if err := w.validate(ap.Schema, obj[k], joinPath(path, k), false); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		// the type meta and object meta of resources are always allowed
+		if (root || s.EmbeddedResource) && (k == "apiVersion" || k == "kind" || k == "metadata") {
+			continue
+		}
+		if s.PreserveUnknownFields || (len(s.Properties) == 0 && s.AdditionalProperties == nil) {
+			continue
+		}
+		w.errorf(joinPath(path, k), "unknown field")
+	}
+	return nil
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func isString(v interface{}) bool {
+	_, ok := v.(string)
+	return ok
+}
+
+func isInteger(v interface{}) bool {
+	switch n := v.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return n == float64(int64(n))
+	}
+	return false
+}
+
+func isNumber(v interface{}) bool {
+	switch v.(type) {
+	case int, int32, int64, float64:
+		return true
+	}
+	return false
+}
+
+func typeOf(v interface{}) string {
+	switch {
+	case isString(v):
+		return "string"
+	case isInteger(v):
+		return "integer"
+	case isNumber(v):
+		return "number"
+	}
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,257 @@
+package validate
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/appleboy/deploy-k8s/template"
+)
+
+func parseObjects(t *testing.T, file, data string) []*template.KubeObject {
+	t.Helper()
+	objs, err := template.ParseObject([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kubeObjs := make([]*template.KubeObject, 0, len(objs))
+	for i := range objs {
+		kubeObjs = append(kubeObjs, &template.KubeObject{
+			TplPath: file,
+			GVK:     objs[i].GroupVersionKind(),
+			Obj:     &objs[i],
+		})
+	}
+	return kubeObjs
+}
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  creationTimestamp: null
+  labels:
+    app: web
+spec:
+  replicas: 2
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25
+          ports:
+            - containerPort: 80
+              targetPort: http
+          resources:
+            limits:
+              cpu: 1
+              memory: 128Mi
+`
+
+const invalidDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+  labels:
+    replicas: 3
+spec:
+  replicas: "2"
+  replica: 2
+  template:
+    spec:
+      hostNetwork: "true"
+      containers:
+        - image: api:v1
+          ports:
+            - containerPort: 80
+              targetPort: true
+          resources:
+            limits:
+              cpu: [1]
+`
+
+func TestValidate(t *testing.T) {
+	v, err := New(WithSchemaLocation("testdata"), WithCRDs("testdata/crd.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	objs := parseObjects(t, "deploy.yaml", deployment+"---\n"+invalidDeployment)
+	objs = append(objs, parseObjects(t, "crontab.yaml", `apiVersion: stable.example.com/v1
+kind: CronTab
+metadata:
+  name: valid
+spec:
+  cronSpec: "* * * * */5"
+  config:
+    anything: true
+---
+apiVersion: stable.example.com/v1
+kind: CronTab
+metadata:
+  name: invalid
+spec:
+  replicas: one
+  image: nginx
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: no-schema
+`)...)
+
+	err = v.Validate(objs)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Validate() error = %v, want Errors", err)
+	}
+
+	got := make([]string, 0, len(errs))
+	for _, e := range errs {
+		got = append(got, e.Error())
+	}
+	want := []string{
+		"deploy.yaml[1] Deployment/api: metadata.labels.replicas: expected string, got integer",
+		"deploy.yaml[1] Deployment/api: spec.selector: required field is missing",
+		"deploy.yaml[1] Deployment/api: spec.replica: unknown field",
+		"deploy.yaml[1] Deployment/api: spec.replicas: expected integer, got string",
+		"deploy.yaml[1] Deployment/api: spec.template.spec.containers[0].name: required field is missing",
+		"deploy.yaml[1] Deployment/api: spec.template.spec.containers[0].ports[0].targetPort: expected integer or string, got boolean",
+		"deploy.yaml[1] Deployment/api: spec.template.spec.containers[0].resources.limits.cpu: expected quantity, got array",
+		"deploy.yaml[1] Deployment/api: spec.template.spec.hostNetwork: expected boolean, got string",
+		"crontab.yaml[1] CronTab/invalid: spec.cronSpec: required field is missing",
+		"crontab.yaml[1] CronTab/invalid: spec.image: unknown field",
+		"crontab.yaml[1] CronTab/invalid: spec.replicas: expected integer, got string",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() errors\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestValidateDownload(t *testing.T) {
+	content, err := os.ReadFile("testdata/apis__apps__v1_openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1.28.0/apis__apps__v1_openapi.json" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+
+	old := schemaURL
+	schemaURL = server.URL + "/%s/%s"
+	defer func() { schemaURL = old }()
+
+	cache := t.TempDir()
+	objs := parseObjects(t, "deploy.yaml", deployment)
+	for i := 0; i < 2; i++ {
+		v, err := New(WithKubeVersion("v1.28.0"), WithCacheDir(cache))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Validate(objs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want 1 with the cache", requests)
+	}
+	if _, err := os.Stat(filepath.Join(cache, "v1.28.0", "apis__apps__v1_openapi.json")); err != nil {
+		t.Error(err)
+	}
+
+	// a group version not in the kubernetes version is skipped
+	v, err := New(WithKubeVersion("v1.28.0"), WithCacheDir(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Validate(parseObjects(t, "cm.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateBundled(t *testing.T) {
+	// the bundled schemas are used offline for the same minor version
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected download %s", r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	old := schemaURL
+	schemaURL = server.URL + "/%s/%s"
+	defer func() { schemaURL = old }()
+
+	v, err := New(WithKubeVersion("v1.29.4"), WithCacheDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := parseObjects(t, "deploy.yaml", `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+        - name: web
+          image: nginx:1.25.0
+          ports:
+            - containerPort: 80
+---
+`+invalidDeployment)
+	objs = append(objs, parseObjects(t, "cm.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\ndata:\n  replicas: 3\n")...)
+
+	err = v.Validate(objs)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Validate() error = %v, want Errors", err)
+	}
+	if len(errs) != 9 {
+		t.Errorf("got %d errors, want 9: %v", len(errs), errs)
+	}
+	if last := errs[len(errs)-1].Error(); last != "cm.yaml[0] ConfigMap/cm: data.replicas: expected string, got integer" {
+		t.Errorf("got last error %q", last)
+	}
+}
+
+func TestNewWithoutSchemas(t *testing.T) {
+	if _, err := New(); err == nil {
+		t.Error("expected error without kube version and schema location")
+	}
+}
+
+func TestMinorVersion(t *testing.T) {
+	for version, want := range map[string]string{
+		"v1.29.0": "v1.29",
+		"1.29":    "v1.29",
+		"v1.30":   "v1.30",
+		"latest":  "latest",
+	} {
+		if got := minorVersion(version); got != want {
+			t.Errorf("minorVersion(%q) = %q, want %q", version, got, want)
+		}
+	}
+}
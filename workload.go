@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/appleboy/com/array"
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// workload is a reference to a Deployment, StatefulSet or DaemonSet.
+type workload struct {
+	Kind     string
+	Name     string
+	Resource schema.GroupVersionResource
+}
+
+func (w workload) String() string {
+	return strings.ToLower(w.Kind) + "/" + w.Name
+}
+
+var workloadKinds = map[string]string{
+	"deployment":   "Deployment",
+	"deployments":  "Deployment",
+	"deploy":       "Deployment",
+	"statefulset":  "StatefulSet",
+	"statefulsets": "StatefulSet",
+	"sts":          "StatefulSet",
+	"daemonset":    "DaemonSet",
+	"daemonsets":   "DaemonSet",
+	"ds":           "DaemonSet",
+}
+
+// newWorkload returns the workload reference of the apps/v1 kind.
+func newWorkload(kind, name string) workload {
+	return workload{
+		Kind: kind,
+		Name: name,
+		Resource: schema.GroupVersionResource{
+			Group:    "apps",
+			Version:  "v1",
+			Resource: strings.ToLower(kind) + "s",
+		},
+	}
+}
+
+// parseWorkload parses a workload reference in the [kind/]name format,
+// the kind defaults to deployment.
+func parseWorkload(v string) (workload, error) {
+	kind, name, ok := strings.Cut(v, "/")
+	if !ok {
+		kind, name = "deployment", v
+	}
+	if name == "" {
+		return workload{}, fmt.Errorf("invalid workload %q, expected [kind/]name", v)
+	}
+	k, ok := workloadKinds[strings.ToLower(kind)]
+	if !ok {
+		return workload{}, fmt.Errorf("unsupported workload kind %q, expected deployment, statefulset or daemonset", kind)
+	}
+	return newWorkload(k, name), nil
+}
+
+// workloads returns the workloads given by the deployment names and
+// the workloads of the given kinds matching the label selector.
+func (p *Plugin) workloads(ctx context.Context, dyn dynamic.Interface, kinds ...string) ([]workload, error) {
+	workloads := []workload{}
+	seen := map[string]bool{}
+	add := func(w workload) error {
+		if !array.InSlice(w.Kind, kinds) {
+			return fmt.Errorf("unsupported workload kind %q, expected %s", w.Kind, strings.Join(kinds, ", "))
+		}
+		if !seen[w.String()] {
+			seen[w.String()] = true
+			workloads = append(workloads, w)
+		}
+		return nil
+	}
+
+	for _, v := range p.Config.Deployment {
+		w, err := parseWorkload(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(w); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Config.Selector == "" {
+		return workloads, nil
+	}
+
+	for _, kind := range kinds {
+		res := newWorkload(kind, "").Resource
+		list, err := dyn.Resource(res).
+			Namespace(p.Config.Namespace).
+			List(ctx, metav1.ListOptions{LabelSelector: p.Config.Selector})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			if err := add(newWorkload(kind, item.GetName())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(workloads) == 0 {
+		return nil, fmt.Errorf("no workloads found with selector %q", p.Config.Selector)
+	}
+
+	return workloads, nil
+}
+
+// Restart kubernetes workloads by updating the restartedAt annotation of the pod template,
+// the same as kubectl rollout restart.
+func (p *Plugin) Restart(cfg *rest.Config) error {
+	if p.Config.Namespace == "" {
+		return fmt.Errorf("namespace is required")
+	}
+	if len(p.Config.Deployment) == 0 && p.Config.Selector == "" {
+		return fmt.Errorf("deployment or selector is required")
+	}
+
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	workloads, err := p.workloads(ctx, dyn, "Deployment", "StatefulSet", "DaemonSet")
+	if err != nil {
+		return err
+	}
+
+	envs, err := p.environment()
+	if err != nil {
+		return err
+	}
+	annotations, err := p.deployAnnotations(envs)
+	if err != nil {
+		return err
+	}
+	podAnnotations := map[string]interface{}{
+		"kubectl.kubernetes.io/restartedAt": time.Now().Format(time.RFC3339),
+	}
+	metaAnnotations := map[string]interface{}{}
+	for k, v := range annotations {
+		podAnnotations[k] = v
+		metaAnnotations[k] = v
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": metaAnnotations,
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": podAnnotations,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, w := range workloads {
+		l := log.With().
+			Str("event", eventRestart).
+			Str("namespace", p.Config.Namespace).
+			Str("workload", w.String()).
+			Logger()
+
+		if _, err := dyn.Resource(w.Resource).
+			Namespace(p.Config.Namespace).
+			Patch(ctx, w.Name, types.MergePatchType, patch, metav1.PatchOptions{
+				FieldManager: "deploy-k8s-plugin",
+			}); err != nil {
+			return err
+		}
+		l.Info().Msg("restart workload success")
+	}
+
+	if !p.Config.Wait {
+		return nil
+	}
+
+	for _, w := range workloads {
+		if err := p.waitForRollout(ctx, dyn, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// waitForRollout waits until the workload rollout is finished or the timeout is reached.
+func (p *Plugin) waitForRollout(ctx context.Context, dyn dynamic.Interface, w workload) error {
+	l := log.With().
+		Str("event", eventRollout).
+		Str("namespace", p.Config.Namespace).
+		Str("workload", w.String()).
+		Logger()
+
+	start := time.Now()
+	var lastMsg string
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, p.Config.WaitTimeout, true, func(ctx context.Context) (bool, error) {
+		obj, err := dyn.Resource(w.Resource).
+			Namespace(p.Config.Namespace).
+			Get(ctx, w.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		done, msg, err := rolloutStatus(obj)
+		if err != nil {
+			return false, err
+		}
+		if msg != lastMsg {
+			l.Info().Msg(msg)
+			lastMsg = msg
+		}
+		return done, nil
+	})
+	if err != nil {
+		err = &objectError{
+			Kind:      w.Kind,
+			Namespace: p.Config.Namespace,
+			Name:      w.Name,
+			Err:       fmt.Errorf("wait for %s rollout failed: %w", w, err),
+		}
+	}
+	p.report.AddRollout(p.Config.Namespace, w.String(), start, err)
+	return err
+}
+
+// rolloutStatus returns whether the rollout of the workload is finished,
+// the same rules as kubectl rollout status.
+func rolloutStatus(obj *unstructured.Unstructured) (bool, string, error) {
+	generation := obj.GetGeneration()
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if generation > observed {
+		return false, "waiting for rollout spec update to be observed", nil
+	}
+
+	status := func(field string) int64 {
+		v, _, _ := unstructured.NestedInt64(obj.Object, "status", field)
+		return v
+	}
+
+	switch obj.GetKind() {
+	case "Deployment":
+		conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		for _, c := range conditions {
+			cond, ok := c.(map[string]interface{})
+			if ok && cond["type"] == "Progressing" && cond["reason"] == "ProgressDeadlineExceeded" {
+				return false, "", fmt.Errorf("deployment %q exceeded its progress deadline", obj.GetName())
+			}
+		}
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		updated := status("updatedReplicas")
+		if updated < replicas {
+			return false, fmt.Sprintf("waiting for rollout to finish: %d out of %d new replicas have been updated", updated, replicas), nil
+		}
+		if total := status("replicas"); total > updated {
+			return false, fmt.Sprintf("waiting for rollout to finish: %d old replicas are pending termination", total-updated), nil
+		}
+		if available := status("availableReplicas"); available < updated {
+			return false, fmt.Sprintf("waiting for rollout to finish: %d of %d updated replicas are available", available, updated), nil
+		}
+		return true, "rollout successfully finished", nil
+	case "StatefulSet":
+		replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+		if !found {
+			replicas = 1
+		}
+		if ready := status("readyReplicas"); ready < replicas {
+			return false, fmt.Sprintf("waiting for %d pods to be ready", replicas-ready), nil
+		}
+		current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+		update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+		if current != update {
+			return false, fmt.Sprintf("waiting for rollout to finish: %d out of %d new pods have been updated", status("updatedReplicas"), replicas), nil
+		}
+		return true, "rollout successfully finished", nil
+	case "DaemonSet":
+		desired := status("desiredNumberScheduled")
+		if updated := status("updatedNumberScheduled"); updated < desired {
+			return false, fmt.Sprintf("waiting for rollout to finish: %d out of %d new pods have been updated", updated, desired), nil
+		}
+		if available := status("numberAvailable"); available < desired {
+			return false, fmt.Sprintf("waiting for rollout to finish: %d of %d updated pods are available", available, desired), nil
+		}
+		return true, "rollout successfully finished", nil
+	}
+
+	return false, "", fmt.Errorf("unsupported workload kind %q", obj.GetKind())
+}
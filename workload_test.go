@@ -0,0 +1,141 @@
+package main
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestParseWorkload(t *testing.T) {
+	tests := []struct {
+		value    string
+		kind     string
+		name     string
+		resource string
+	}{
+		{"nginx", "Deployment", "nginx", "deployments"},
+		{"deploy/nginx", "Deployment", "nginx", "deployments"},
+		{"statefulset/redis", "StatefulSet", "redis", "statefulsets"},
+		{"DS/agent", "DaemonSet", "agent", "daemonsets"},
+	}
+
+	for _, tt := range tests {
+		w, err := parseWorkload(tt.value)
+		if err != nil {
+			t.Errorf("Unexpected error for %s: %s", tt.value, err)
+			continue
+		}
+		if w.Kind != tt.kind || w.Name != tt.name || w.Resource.Resource != tt.resource || w.Resource.Group != "apps" {
+			t.Errorf("Unexpected workload for %s: %+v", tt.value, w)
+		}
+	}
+
+	for _, v := range []string{"pod/nginx", "deployment/"} {
+		if _, err := parseWorkload(v); err == nil {
+			t.Errorf("Expected error for %s", v)
+		}
+	}
+}
+
+func TestRolloutStatus(t *testing.T) {
+	newObj := func(kind string, replicas int64, status map[string]interface{}) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"name":       "app",
+				"generation": int64(2),
+			},
+			"spec": map[string]interface{}{
+				"replicas": replicas,
+			},
+			"status": status,
+		}}
+		return obj
+	}
+
+	tests := []struct {
+		name string
+		obj  *unstructured.Unstructured
+		done bool
+	}{
+		{
+			name: "DeploymentNotObserved",
+			obj: newObj("Deployment", 2, map[string]interface{}{
+				"observedGeneration": int64(1),
+			}),
+		},
+		{
+			name: "DeploymentUpdating",
+			obj: newObj("Deployment", 2, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"updatedReplicas":    int64(1),
+				"replicas":           int64(3),
+			}),
+		},
+		{
+			name: "DeploymentDone",
+			obj: newObj("Deployment", 2, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"updatedReplicas":    int64(2),
+				"replicas":           int64(2),
+				"availableReplicas":  int64(2),
+			}),
+			done: true,
+		},
+		{
+			name: "StatefulSetUpdating",
+			obj: newObj("StatefulSet", 2, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"readyReplicas":      int64(2),
+				"currentRevision":    "a",
+				"updateRevision":     "b",
+			}),
+		},
+		{
+			name: "StatefulSetDone",
+			obj: newObj("StatefulSet", 2, map[string]interface{}{
+				"observedGeneration": int64(2),
+				"readyReplicas":      int64(2),
+				"currentRevision":    "b",
+				"updateRevision":     "b",
+			}),
+			done: true,
+		},
+		{
+			name: "DaemonSetDone",
+			obj: newObj("DaemonSet", 0, map[string]interface{}{
+				"observedGeneration":     int64(2),
+				"desiredNumberScheduled": int64(3),
+				"updatedNumberScheduled": int64(3),
+				"numberAvailable":        int64(3),
+			}),
+			done: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			done, msg, err := rolloutStatus(tt.obj)
+			if err != nil {
+				t.Fatalf("Unexpected error: %s", err)
+			}
+			if done != tt.done {
+				t.Errorf("Expected done: %v, got: %v (%s)", tt.done, done, msg)
+			}
+		})
+	}
+
+	failed := newObj("Deployment", 2, map[string]interface{}{
+		"observedGeneration": int64(2),
+		"conditions": []interface{}{
+			map[string]interface{}{
+				"type":   "Progressing",
+				"reason": "ProgressDeadlineExceeded",
+			},
+		},
+	})
+	if _, _, err := rolloutStatus(failed); err == nil {
+		t.Errorf("Expected error for exceeded progress deadline")
+	}
+}